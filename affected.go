@@ -0,0 +1,172 @@
+package pkgwatcher
+
+import (
+	"path/filepath"
+	"sort"
+)
+
+// Find the import paths affected by changes to the given files, which
+// are the packages containing them along with every resolved package
+// that transitively imports one of those. Relative file names are
+// interpreted relative to the working directory.
+func (w *Watcher) AffectedPackages(files []string) []string {
+	return w.affected(files, false)
+}
+
+// Find the import paths whose tests are affected by changes to the
+// given files: the affected packages along with every resolved package
+// whose tests import one of those. Relative file names are interpreted
+// relative to the working directory.
+func (w *Watcher) AffectedTests(files []string) []string {
+	return w.affected(files, true)
+}
+
+// Find the affected import paths, including the packages whose tests
+// import an affected package if tests is set.
+func (w *Watcher) affected(files []string, tests bool) []string {
+	importers := w.reverseImports(false)
+	var testImporters map[string][]string
+	if tests {
+		testImporters = w.reverseImports(true)
+	}
+	affected := make(map[string]bool)
+	expanded := make(map[string]bool)
+	var queue []string
+	for _, file := range files {
+		if pkg := w.findPackage(w.absPath(file)); pkg != nil {
+			queue = append(queue, pkg.ImportPath)
+		}
+	}
+	for len(queue) > 0 {
+		path := queue[0]
+		queue = queue[1:]
+		if expanded[path] {
+			continue
+		}
+		expanded[path] = true
+		affected[path] = true
+		queue = append(queue, importers[path]...)
+		// importers of a package whose tests are affected are not
+		for _, importer := range testImporters[path] {
+			affected[importer] = true
+		}
+	}
+
+	paths := make([]string, 0, len(affected))
+	for path := range affected {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// Explains why a root package is affected by a changed file.
+type CauseChain struct {
+	File string   // the changed file
+	Path []string // import paths from the file's package to the root
+}
+
+// Find the shortest import chain from each changed file to every
+// affected root package, answering why a root needs to be rebuilt.
+func (w *Watcher) AffectedCauses(files []string) []*CauseChain {
+	importers := w.reverseImports(false)
+	var chains []*CauseChain
+	for _, file := range files {
+		file = w.absPath(file)
+		pkg := w.findPackage(file)
+		if pkg == nil {
+			continue
+		}
+		prev := map[string]string{pkg.ImportPath: ""}
+		queue := []string{pkg.ImportPath}
+		for len(queue) > 0 {
+			path := queue[0]
+			queue = queue[1:]
+			if w.isRoot(path) {
+				chain := &CauseChain{File: file}
+				for p := path; p != ""; p = prev[p] {
+					chain.Path = append([]string{p}, chain.Path...)
+				}
+				chains = append(chains, chain)
+			}
+			for _, importer := range importers[path] {
+				if _, seen := prev[importer]; !seen {
+					prev[importer] = path
+					queue = append(queue, importer)
+				}
+			}
+		}
+	}
+	return chains
+}
+
+// Check if an import path was watched as a root.
+func (w *Watcher) isRoot(importPath string) bool {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	depth, ok := w.depth[importPath]
+	return ok && depth == 0
+}
+
+// Make a file name absolute relative to the working directory.
+func (w *Watcher) absPath(file string) string {
+	if !filepath.IsAbs(file) {
+		file = filepath.Join(w.workingDirectory, file)
+	}
+	return filepath.Clean(file)
+}
+
+// Find the import paths affected by changes to the given files without
+// watching anything, by resolving the given import paths and their
+// dependencies. Useful for selecting tests to run in CI from the output
+// of git diff --name-only. The first resolution error, if any, is
+// returned along with the packages that could be resolved.
+func AffectedPackages(importPaths []string, wd string, files []string) ([]string, error) {
+	return affectedStandalone(importPaths, wd, files, false)
+}
+
+// Find the import paths whose tests are affected by changes to the given
+// files without watching anything, resolving the given import paths with
+// the imports of their tests. See AffectedPackages.
+func AffectedTests(importPaths []string, wd string, files []string) ([]string, error) {
+	return affectedStandalone(importPaths, wd, files, true)
+}
+
+func affectedStandalone(importPaths []string, wd string, files []string, tests bool) ([]string, error) {
+	w := newWatcher(wd)
+	errs := make(chan error)
+	go func() {
+		var first error
+		for err := range w.Error {
+			if first == nil {
+				first = err
+			}
+		}
+		errs <- first
+	}()
+	for _, p := range importPaths {
+		w.resolve(p, false, RootOptions{Tests: tests})
+	}
+	close(w.Error)
+	return w.affected(files, tests), <-errs
+}
+
+// Map each resolved import path to the resolved packages importing it,
+// or if tests is set, to those whose tests import it.
+func (w *Watcher) reverseImports(tests bool) map[string][]string {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	importers := make(map[string][]string)
+	for _, pkg := range w.Packages {
+		imports := pkg.Imports
+		if tests {
+			imports = append(append([]string(nil), pkg.TestImports...), pkg.XTestImports...)
+		}
+		for _, path := range imports {
+			if dep := w.Packages[path]; dep != nil {
+				importers[dep.ImportPath] = append(importers[dep.ImportPath], pkg.ImportPath)
+			}
+		}
+	}
+	return importers
+}
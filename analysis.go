@@ -0,0 +1,285 @@
+package pkgwatcher
+
+import (
+	"crypto/sha1"
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/types"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// Symbol level dependency information used to tell if a change can
+// affect dependent packages.
+type analysis struct {
+	mu       sync.Mutex
+	enabled  bool
+	analyzed map[string]bool                       // by importing package
+	uses     map[string]map[string]map[string]bool // dep -> ident -> importer
+	decls    map[string]map[string]string          // file -> decl -> hash
+	running  map[string]bool                       // by pkg.Dir, true if another run is wanted
+	cache    astCache
+}
+
+// Enable or disable symbol level analysis. When enabled, watched
+// packages are type checked to record the identifiers they use from
+// their dependencies, and Event.NoImpact is set for changes to Go files
+// that only touch declarations no dependent uses. Analysis applies to
+// packages watched afterwards.
+func (w *Watcher) SetAnalysis(enabled bool) {
+	w.analysis.mu.Lock()
+	defer w.analysis.mu.Unlock()
+	w.analysis.enabled = enabled
+	if enabled && w.analysis.analyzed == nil {
+		w.analysis.analyzed = make(map[string]bool)
+		w.analysis.uses = make(map[string]map[string]map[string]bool)
+		w.analysis.decls = make(map[string]map[string]string)
+	}
+}
+
+// Check if analysis is enabled.
+func (a *analysis) on() bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.enabled
+}
+
+// Analyze a package unless it has already been analyzed.
+func (w *Watcher) analyzePackage(pkg *build.Package) {
+	a := &w.analysis
+	if !a.on() || pkg.Goroot {
+		return
+	}
+	a.mu.Lock()
+	done := a.analyzed[pkg.ImportPath]
+	a.analyzed[pkg.ImportPath] = true
+	a.mu.Unlock()
+	if done {
+		return
+	}
+	w.recordUses(pkg)
+	for _, name := range pkg.GoFiles {
+		file := filepath.Join(pkg.Dir, name)
+		if decls, err := a.declHashes(file); err == nil {
+			a.mu.Lock()
+			a.decls[file] = decls
+			a.mu.Unlock()
+		}
+	}
+}
+
+// Type check a package and record the identifiers it uses from other
+// packages.
+func (w *Watcher) recordUses(pkg *build.Package) {
+	a := &w.analysis
+	var names []string
+	for _, name := range pkg.GoFiles {
+		names = append(names, filepath.Join(pkg.Dir, name))
+	}
+	info := &types.Info{
+		Uses:       make(map[*ast.Ident]types.Object),
+		Selections: make(map[*ast.SelectorExpr]*types.Selection),
+	}
+	r := w.resolverFor(RootOptions{})
+	if checked, errs := a.cache.check(r, pkg.ImportPath, names, info); checked == nil && len(errs) > 0 {
+		w.reportError(fmt.Errorf(
+			"Analysis of %s failed: %s", pkg.ImportPath, errs[0]))
+		return
+	}
+
+	used := make(map[string]map[string]bool)
+	record := func(obj types.Object, name string) {
+		if obj == nil || obj.Pkg() == nil || obj.Pkg().Path() == pkg.ImportPath {
+			return
+		}
+		if used[obj.Pkg().Path()] == nil {
+			used[obj.Pkg().Path()] = make(map[string]bool)
+		}
+		used[obj.Pkg().Path()][name] = true
+	}
+	for _, obj := range info.Uses {
+		record(obj, objectName(obj))
+	}
+	for _, sel := range info.Selections {
+		if named := namedType(sel.Recv()); named != nil {
+			record(named.Obj(), named.Obj().Name())
+		}
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for _, idents := range a.uses {
+		for _, importers := range idents {
+			delete(importers, pkg.ImportPath)
+		}
+	}
+	for dep, idents := range used {
+		if a.uses[dep] == nil {
+			a.uses[dep] = make(map[string]map[string]bool)
+		}
+		for ident := range idents {
+			if a.uses[dep][ident] == nil {
+				a.uses[dep][ident] = make(map[string]bool)
+			}
+			a.uses[dep][ident][pkg.ImportPath] = true
+		}
+	}
+}
+
+// Update the analysis for a changed file and report if the change can
+// not affect any dependent of the package. The uses of the package itself
+// are recorded again in the background.
+func (w *Watcher) noImpact(pkg *build.Package, file string) bool {
+	a := &w.analysis
+	if !a.on() || filepath.Ext(file) != ".go" ||
+		strings.HasSuffix(file, "_test.go") {
+		return false
+	}
+	decls, err := a.declHashes(file)
+	if err != nil {
+		decls = nil // treat as every declaration being removed
+	}
+
+	a.mu.Lock()
+	old, known := a.decls[file]
+	a.decls[file] = decls
+	a.mu.Unlock()
+	if !known {
+		w.reanalyze(pkg)
+		return false
+	}
+	changed := make(map[string]bool)
+	for name, hash := range decls {
+		if old[name] != hash {
+			changed[name] = true
+		}
+	}
+	for name := range old {
+		if _, ok := decls[name]; !ok {
+			changed[name] = true
+		}
+	}
+	if len(changed) > 0 {
+		w.reanalyze(pkg)
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for name := range changed {
+		if len(a.uses[pkg.ImportPath][name]) > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// Record the uses of a changed package again in the background, after
+// forgetting it and its importers in the cache. Changes arriving during a
+// run cause one more run once it is done.
+func (w *Watcher) reanalyze(pkg *build.Package) {
+	a := &w.analysis
+	a.mu.Lock()
+	if _, ok := a.running[pkg.Dir]; ok {
+		a.running[pkg.Dir] = true
+		a.mu.Unlock()
+		return
+	}
+	if a.running == nil {
+		a.running = make(map[string]bool)
+	}
+	a.running[pkg.Dir] = false
+	a.mu.Unlock()
+
+	w.spawn(func() {
+		for {
+			if current := w.dirPackage(pkg.Dir); current != nil {
+				pkg = current
+			}
+			a.cache.invalidate(pkg.Dir)
+			w.protect("analysis", func() { w.recordUses(pkg) })
+			a.mu.Lock()
+			if !a.running[pkg.Dir] {
+				delete(a.running, pkg.Dir)
+				a.mu.Unlock()
+				return
+			}
+			a.running[pkg.Dir] = false
+			a.mu.Unlock()
+		}
+	})
+}
+
+// The name used to identify an object, qualified by the receiver type
+// for methods.
+func objectName(obj types.Object) string {
+	if fn, ok := obj.(*types.Func); ok {
+		if sig, ok := fn.Type().(*types.Signature); ok && sig.Recv() != nil {
+			if named := namedType(sig.Recv().Type()); named != nil {
+				return named.Obj().Name() + "." + fn.Name()
+			}
+		}
+	}
+	return obj.Name()
+}
+
+// The named type, if any, possibly behind a pointer.
+func namedType(t types.Type) *types.Named {
+	if p, ok := t.(*types.Pointer); ok {
+		t = p.Elem()
+	}
+	named, _ := t.(*types.Named)
+	return named
+}
+
+// Hash the source of every top level declaration in a file.
+func (a *analysis) declHashes(file string) (map[string]string, error) {
+	p, err := a.cache.parse(file)
+	if err != nil {
+		return nil, err
+	}
+	fset, src, f := p.fset, p.src, p.file
+	hash := func(n ast.Node) string {
+		start := fset.Position(n.Pos()).Offset
+		end := fset.Position(n.End()).Offset
+		return fmt.Sprintf("%x", sha1.Sum(src[start:end]))
+	}
+	decls := make(map[string]string)
+	for _, decl := range f.Decls {
+		switch d := decl.(type) {
+		case *ast.FuncDecl:
+			name := d.Name.Name
+			if d.Recv != nil && len(d.Recv.List) > 0 {
+				name = recvName(d.Recv.List[0].Type) + "." + name
+			}
+			decls[name] = hash(d)
+		case *ast.GenDecl:
+			for _, spec := range d.Specs {
+				switch s := spec.(type) {
+				case *ast.TypeSpec:
+					decls[s.Name.Name] = hash(s)
+				case *ast.ValueSpec:
+					for _, n := range s.Names {
+						decls[n.Name] = hash(d)
+					}
+				}
+			}
+		}
+	}
+	return decls, nil
+}
+
+// The type name of a method receiver expression.
+func recvName(expr ast.Expr) string {
+	switch e := expr.(type) {
+	case *ast.StarExpr:
+		return recvName(e.X)
+	case *ast.IndexExpr:
+		return recvName(e.X)
+	case *ast.IndexListExpr:
+		return recvName(e.X)
+	case *ast.Ident:
+		return e.Name
+	}
+	return ""
+}
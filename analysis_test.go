@@ -0,0 +1,90 @@
+package pkgwatcher
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const analysisQ = "package q\n\nfunc F() int { return 1 }\n\nfunc G() int { return 2 }\n"
+
+// Watch package p using q.F with analysis enabled.
+func analysisWatcher(t *testing.T) (*Watcher, string) {
+	gopath := tempGopath(t, map[string]string{
+		"p/p.go": "package p\n\nimport \"q\"\n\nvar V = q.F()\n",
+		"q/q.go": analysisQ,
+	})
+	w, _ := testWatcher(t, gopath, filepath.Join(gopath, "src", "p"))
+	w.SetAnalysis(true)
+	w.WatchImportPath("p", false)
+	return w, gopath
+}
+
+// Change a file, returning the event for it.
+func changeFile(t *testing.T, w *Watcher, name, content string) *Event {
+	if err := os.WriteFile(name, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	injectFile(w, name, OpModify)
+	return nextEvent(t, w)
+}
+
+// Check if an importer uses an identifier of a dependency.
+func uses(w *Watcher, dep, ident, importer string) bool {
+	w.analysis.mu.Lock()
+	defer w.analysis.mu.Unlock()
+	return w.analysis.uses[dep][ident][importer]
+}
+
+func TestNoImpact(t *testing.T) {
+	w, gopath := analysisWatcher(t)
+	q := filepath.Join(gopath, "src", "q", "q.go")
+	if ev := changeFile(t, w, q, analysisQ+"\n// a comment\n"); !ev.NoImpact {
+		t.Error("adding a comment has an impact")
+	}
+	if ev := changeFile(t, w, q, "package q\n\nfunc F() int { return 1 }\n\nfunc G() int { return 3 }\n"); !ev.NoImpact {
+		t.Error("changing the unused q.G has an impact")
+	}
+	if ev := changeFile(t, w, q, "package q\n\nfunc F() int { return 4 }\n\nfunc G() int { return 3 }\n"); ev.NoImpact {
+		t.Error("changing the used q.F has no impact")
+	}
+}
+
+func TestAnalysisOffEventPath(t *testing.T) {
+	w, gopath := analysisWatcher(t)
+	p := filepath.Join(gopath, "src", "p", "p.go")
+	// a long running check
+	w.analysis.cache.checkMu.Lock()
+	changeFile(t, w, p, "package p\n\nimport \"q\"\n\nvar V = q.F() + q.G()\n")
+	q := filepath.Join(gopath, "src", "q", "q.go")
+	changeFile(t, w, q, analysisQ+"\n// a comment\n")
+	w.analysis.cache.checkMu.Unlock()
+
+	waitFor(t, "p to use q.G", func() bool { return uses(w, "q", "G", "p") })
+	if ev := changeFile(t, w, q, "package q\n\nfunc F() int { return 1 }\n\nfunc G() int { return 3 }\n"); ev.NoImpact {
+		t.Error("changing the used q.G has no impact")
+	}
+}
+
+// Deliver events changing a declaration of a package importing net/http.
+func BenchmarkNoImpactEvent(b *testing.B) {
+	gopath := tempGopath(b, map[string]string{
+		"p/p.go": "package p\n\nimport \"net/http\"\n\nvar C = http.DefaultClient\n",
+	})
+	w, _ := testWatcher(b, gopath, filepath.Join(gopath, "src", "p"))
+	// imports vendored in GOROOT are not found outside of it
+	w.SetErrorHandler(func(error) {})
+	w.SetAnalysis(true)
+	w.WatchImportPath("p", false)
+	name := filepath.Join(gopath, "src", "p", "p.go")
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		src := fmt.Sprintf("package p\n\nimport \"net/http\"\n\nvar C = http.DefaultClient\n\nvar N = %d\n", i)
+		if err := os.WriteFile(name, []byte(src), 0644); err != nil {
+			b.Fatal(err)
+		}
+		injectFile(w, name, OpModify)
+		nextEvent(b, w)
+	}
+}
@@ -0,0 +1,51 @@
+package pkgwatcher
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Conventional directories holding non-Go assets for a package. Add
+// "testdata" to also treat test fixtures as package assets.
+var DefaultAssetDirs = []string{"templates", "static", "migrations"}
+
+// Set the names of the directories inside a package that hold its
+// non-Go assets. Events for files inside them are attributed to the
+// package and have Event.AssetDir set. Changes apply to packages
+// watched afterwards.
+func (w *Watcher) SetAssetDirs(names ...string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.assetDirs = append([]string(nil), names...)
+}
+
+// Watch the asset directories that exist inside the package.
+func (w *Watcher) watchAssetDirs(dir string) {
+	w.mu.RLock()
+	names := w.assetDirs
+	w.mu.RUnlock()
+	for _, name := range names {
+		path := filepath.Join(dir, name)
+		if info, err := os.Stat(path); err == nil && info.IsDir() {
+			w.WatchDirectory(path)
+		}
+	}
+}
+
+// The asset directory containing a file given its path relative to the
+// package directory, if any.
+func (w *Watcher) assetDir(rel string) string {
+	first := strings.SplitN(filepath.ToSlash(rel), "/", 2)
+	if len(first) < 2 {
+		return ""
+	}
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	for _, name := range w.assetDirs {
+		if first[0] == name {
+			return name
+		}
+	}
+	return ""
+}
@@ -0,0 +1,279 @@
+package pkgwatcher
+
+import (
+	"crypto/sha1"
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// Size of the file set past which the cache starts over, so repeated
+// parsing of changed files does not grow it without bound.
+const maxFileSetBase = 64 << 20
+
+// A parsed source file along with the hash of its contents.
+type parsedFile struct {
+	hash [sha1.Size]byte
+	src  []byte
+	file *ast.File
+	fset *token.FileSet // the file set positions in file refer to
+}
+
+// A type checked package imported by an analyzed package.
+type checkedPackage struct {
+	pkg       *types.Package
+	importers map[string]bool // directories of the packages importing it
+}
+
+// Caches parsed files by content hash, and imported packages between
+// type checks, so analysis only re-parses the files that changed and
+// re-checks the packages depending on them. The file set is replaced
+// along with the imported packages, dropping the files parsed into the
+// previous one.
+type astCache struct {
+	mu       sync.Mutex
+	fset     *token.FileSet
+	files    map[string]*parsedFile     // by file name
+	packages map[string]*checkedPackage // by directory
+	located  map[string]string          // import path and source directory -> directory
+	gen      int                        // incremented when packages are dropped
+	checkMu  sync.Mutex                 // serializes type checks
+}
+
+// Parse a file, reusing the previous result if its content is unchanged.
+func (c *astCache) parse(name string) (*parsedFile, error) {
+	src, err := ioutil.ReadFile(name)
+	if err != nil {
+		c.mu.Lock()
+		delete(c.files, name)
+		c.mu.Unlock()
+		return nil, err
+	}
+	hash := sha1.Sum(src)
+	c.mu.Lock()
+	if c.fset == nil {
+		c.reset()
+	}
+	fset := c.fset
+	if p := c.files[name]; p != nil && p.hash == hash {
+		c.mu.Unlock()
+		return p, nil
+	}
+	c.mu.Unlock()
+
+	f, err := parser.ParseFile(fset, name, src, 0)
+	if err != nil {
+		return nil, err
+	}
+	p := &parsedFile{hash: hash, src: src, file: f, fset: fset}
+	c.mu.Lock()
+	if c.fset == fset {
+		c.files[name] = p
+	}
+	c.mu.Unlock()
+	return p, nil
+}
+
+// Start over with a new file set. Must be called with the lock held.
+func (c *astCache) reset() {
+	c.fset = token.NewFileSet()
+	c.files = make(map[string]*parsedFile)
+	c.discardPackages()
+}
+
+// Forget all imported packages. Must be called with the lock held.
+func (c *astCache) discardPackages() {
+	c.packages = make(map[string]*checkedPackage)
+	c.located = make(map[string]string)
+	c.gen++
+}
+
+// Parse and type check files, importing packages found with the given
+// Resolver, returning the package and the type errors. If a file fails
+// to parse only the parse errors are returned.
+func (c *astCache) check(r Resolver, path string, names []string, info *types.Info) (*types.Package, []error) {
+	c.checkMu.Lock()
+	defer c.checkMu.Unlock()
+	c.mu.Lock()
+	if c.fset == nil || c.fset.Base() > maxFileSetBase {
+		c.reset()
+	}
+	fset := c.fset
+	c.mu.Unlock()
+
+	// the file set only changes with the check lock held
+	var errs []error
+	var files []*ast.File
+	for _, name := range names {
+		p, err := c.parse(name)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		files = append(files, p.file)
+	}
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	conf := types.Config{
+		Importer: &sourceImporter{c: c, resolver: r, fset: fset, importing: make(map[string]bool)},
+		// use whatever could be checked
+		Error: func(err error) { errs = append(errs, err) },
+	}
+	pkg, _ := conf.Check(path, fset, files, info)
+	return pkg, errs
+}
+
+// Forget the imported package in a directory and the packages importing
+// it, after a change to its files.
+func (c *astCache) invalidate(dir string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.drop(dir)
+}
+
+// Forget an imported package and its importers. Must be called with the
+// lock held.
+func (c *astCache) drop(dir string) {
+	p := c.packages[dir]
+	if p == nil {
+		return
+	}
+	delete(c.packages, dir)
+	c.gen++
+	for importer := range p.importers {
+		c.drop(importer)
+	}
+}
+
+// Forget all imported packages, after the build context changed.
+func (c *astCache) invalidateAll() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.discardPackages()
+}
+
+// Forget the parsed files and imported packages with the given
+// directory prefix.
+func (c *astCache) forget(prefix string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for name := range c.files {
+		if strings.HasPrefix(name, prefix) {
+			delete(c.files, name)
+		}
+	}
+	for dir := range c.packages {
+		if strings.HasPrefix(dir+string(filepath.Separator), prefix) {
+			c.drop(dir)
+		}
+	}
+}
+
+// The total size of the cached sources, plus the ASTs of everything
+// parsed into the file set including the imported packages.
+func (c *astCache) size() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	n := 0
+	for name, p := range c.files {
+		n += len(name) + len(p.src)
+	}
+	if c.fset != nil {
+		n += c.fset.Base() // an AST is about as big as its source
+	}
+	return n
+}
+
+// Imports packages by type checking their source, found with a Resolver
+// so the build context of the Watcher applies.
+type sourceImporter struct {
+	c         *astCache
+	resolver  Resolver
+	fset      *token.FileSet
+	importing map[string]bool // directories being checked, to detect cycles
+}
+
+func (im *sourceImporter) Import(path string) (*types.Package, error) {
+	return im.ImportFrom(path, "", 0)
+}
+
+func (im *sourceImporter) ImportFrom(path, srcDir string, mode types.ImportMode) (*types.Package, error) {
+	if path == "unsafe" {
+		return types.Unsafe, nil
+	}
+	c := im.c
+	key := path + "\x00" + srcDir
+	c.mu.Lock()
+	if dir, ok := c.located[key]; ok {
+		if p := c.packages[dir]; p != nil {
+			p.importers[srcDir] = true
+			c.mu.Unlock()
+			return p.pkg, nil
+		}
+	}
+	gen := c.gen
+	c.mu.Unlock()
+
+	bp, err := im.resolver.Import(path, srcDir, 0)
+	if err != nil {
+		return nil, err
+	}
+	if im.importing[bp.Dir] {
+		return nil, fmt.Errorf("Import cycle through %s", path)
+	}
+	c.mu.Lock()
+	c.located[key] = bp.Dir
+	if p := c.packages[bp.Dir]; p != nil {
+		p.importers[srcDir] = true
+		c.mu.Unlock()
+		return p.pkg, nil
+	}
+	c.mu.Unlock()
+
+	pkg, err := im.checkImport(bp)
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	// packages dropped meanwhile may have been checked from old sources
+	if c.gen == gen {
+		c.packages[bp.Dir] = &checkedPackage{
+			pkg:       pkg,
+			importers: map[string]bool{srcDir: true},
+		}
+	}
+	return pkg, nil
+}
+
+// Type check an imported package, ignoring type errors as those are
+// reported for the package itself.
+func (im *sourceImporter) checkImport(bp *build.Package) (*types.Package, error) {
+	im.importing[bp.Dir] = true
+	defer delete(im.importing, bp.Dir)
+	var files []*ast.File
+	for _, set := range [][]string{bp.GoFiles, bp.CgoFiles} {
+		for _, name := range set {
+			p, err := im.c.parse(filepath.Join(bp.Dir, name))
+			if err != nil {
+				return nil, err
+			}
+			files = append(files, p.file)
+		}
+	}
+	conf := types.Config{
+		Importer:    im,
+		FakeImportC: true,
+		Error:       func(error) {},
+	}
+	pkg, _ := conf.Check(bp.ImportPath, im.fset, files, nil)
+	return pkg, nil
+}
@@ -0,0 +1,36 @@
+package pkgwatcher
+
+import (
+	"github.com/howeyc/fsnotify"
+)
+
+// A Backend delivers file system notifications for watched directories.
+type Backend interface {
+	Watch(path string) error
+	RemoveWatch(path string) error
+	Events() <-chan *fsnotify.FileEvent
+	Errors() <-chan error
+	Close() error
+}
+
+// The default Backend using fsnotify.
+type fsnotifyBackend struct {
+	*fsnotify.Watcher
+}
+
+// Create a Backend using fsnotify.
+func NewFsnotifyBackend() (Backend, error) {
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	return fsnotifyBackend{w}, nil
+}
+
+func (b fsnotifyBackend) Events() <-chan *fsnotify.FileEvent {
+	return b.Event
+}
+
+func (b fsnotifyBackend) Errors() <-chan error {
+	return b.Error
+}
@@ -0,0 +1,81 @@
+package pkgwatcher
+
+import (
+	"time"
+)
+
+// A source of time for debouncing, quiet periods, grace periods, polling,
+// retries and event timestamps. Tests and embedders with simulated time
+// can provide their own using WithClock.
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+	AfterFunc(d time.Duration, f func()) Timer
+}
+
+// A timer created by a Clock, see time.Timer.
+type Timer interface {
+	Stop() bool
+	Reset(d time.Duration) bool
+}
+
+// The Clock used unless one is given using WithClock.
+var RealClock Clock = realClock{}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time                         { return time.Now() }
+func (realClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+func (realClock) AfterFunc(d time.Duration, f func()) Timer {
+	return time.AfterFunc(d, f)
+}
+
+// Run f in a goroutine that Close waits for once the duration has
+// passed, unless the Watcher is closed by then.
+func (w *Watcher) afterFunc(d time.Duration, f func()) Timer {
+	return w.clock.AfterFunc(d, func() { w.spawn(f) })
+}
+
+// Stop the timers of debouncing, quiet periods, removal grace periods and
+// toolchain changes.
+func (w *Watcher) stopTimers() {
+	d := &w.debouncer
+	d.mu.Lock()
+	for name, p := range d.pending {
+		p.timer.Stop()
+		p.ev.traceEnd("dropped")
+		delete(d.pending, name)
+	}
+	d.mu.Unlock()
+	s := &w.settler
+	s.mu.Lock()
+	for dir, t := range s.timers {
+		t.Stop()
+		delete(s.timers, dir)
+	}
+	s.mu.Unlock()
+	r := &w.remover
+	r.mu.Lock()
+	for dir, t := range r.pending {
+		t.Stop()
+		delete(r.pending, dir)
+	}
+	r.mu.Unlock()
+	t := &w.toolchain
+	t.mu.Lock()
+	if t.timer != nil {
+		t.timer.Stop()
+		t.timer = nil
+	}
+	t.mu.Unlock()
+}
+
+// Use the given Clock for all time based behavior instead of the system
+// clock.
+func WithClock(c Clock) Option {
+	return func(w *Watcher) {
+		if c != nil {
+			w.clock = c
+		}
+	}
+}
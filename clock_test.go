@@ -0,0 +1,163 @@
+package pkgwatcher
+
+import (
+	"github.com/howeyc/fsnotify"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// A Clock whose time only moves when advanced by the test.
+type fakeClock struct {
+	mu     sync.Mutex
+	now    time.Time
+	timers []*fakeTimer // pending, in no particular order
+}
+
+// A pending call or channel send of a fakeClock.
+type fakeTimer struct {
+	c  *fakeClock
+	at time.Time
+	f  func()         // called when due, for AfterFunc
+	ch chan time.Time // sent to when due, for After
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	t := &fakeTimer{c: c, ch: make(chan time.Time, 1)}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.schedule(t, d)
+	return t.ch
+}
+
+func (c *fakeClock) AfterFunc(d time.Duration, f func()) Timer {
+	t := &fakeTimer{c: c, f: f}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.schedule(t, d)
+	return t
+}
+
+func (t *fakeTimer) Stop() bool {
+	t.c.mu.Lock()
+	defer t.c.mu.Unlock()
+	return t.c.unschedule(t)
+}
+
+func (t *fakeTimer) Reset(d time.Duration) bool {
+	t.c.mu.Lock()
+	defer t.c.mu.Unlock()
+	pending := t.c.unschedule(t)
+	t.c.schedule(t, d)
+	return pending
+}
+
+// Add a timer due after d. Must be called with the lock held.
+func (c *fakeClock) schedule(t *fakeTimer, d time.Duration) {
+	t.at = c.now.Add(d)
+	c.timers = append(c.timers, t)
+}
+
+// Remove a timer, returning false if it was not pending. Must be called
+// with the lock held.
+func (c *fakeClock) unschedule(t *fakeTimer) bool {
+	for i, pending := range c.timers {
+		if pending == t {
+			c.timers = append(c.timers[:i], c.timers[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// Move time forward, firing the timers due by then in order.
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	end := c.now.Add(d)
+	for {
+		var next *fakeTimer
+		for _, t := range c.timers {
+			if !t.at.After(end) && (next == nil || t.at.Before(next.at)) {
+				next = t
+			}
+		}
+		if next == nil {
+			break
+		}
+		c.unschedule(next)
+		c.now = next.at
+		c.mu.Unlock()
+		if next.f != nil {
+			next.f()
+		} else {
+			next.ch <- next.at
+		}
+		c.mu.Lock()
+	}
+	c.now = end
+	c.mu.Unlock()
+}
+
+// Wait until at least n channels returned by After are pending.
+func (c *fakeClock) blockUntil(t testing.TB, n int) {
+	waitFor(t, "goroutines waiting on the clock", func() bool {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		waiting := 0
+		for _, t := range c.timers {
+			if t.ch != nil {
+				waiting++
+			}
+		}
+		return waiting >= n
+	})
+}
+
+func TestEventTimeFromClock(t *testing.T) {
+	c := newFakeClock()
+	gopath := tempGopath(t, map[string]string{"p/p.go": "package p\n"})
+	w, _ := testWatcher(t, gopath, t.TempDir(), WithClock(c))
+	c.Advance(time.Minute)
+	w.Inject(&Event{})
+	if ev := nextEvent(t, w); !ev.Time.Equal(c.Now()) {
+		t.Fatalf("event time %s, want %s", ev.Time, c.Now())
+	}
+}
+
+func TestCloseStopsTimers(t *testing.T) {
+	c := newFakeClock()
+	gopath := tempGopath(t, map[string]string{"p/p.go": "package p\n"})
+	dir := filepath.Join(gopath, "src", "p")
+	w, _ := testWatcher(t, gopath, dir, WithClock(c), WithDebounce(time.Second))
+	w.SetQuietPeriod(time.Second)
+	w.WatchImportPath("p", false)
+	name := filepath.Join(dir, "p.go")
+	w.Inject(&Event{FileEvent: &fsnotify.FileEvent{Name: name}, Op: OpModify})
+	waitFor(t, "the debounce and quiet period timers", func() bool {
+		w.debouncer.mu.Lock()
+		pending := w.debouncer.pending[name] != nil
+		w.debouncer.mu.Unlock()
+		w.settler.mu.Lock()
+		defer w.settler.mu.Unlock()
+		return pending && w.settler.timers[dir] != nil
+	})
+	w.Close()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, timer := range c.timers {
+		if timer.f != nil {
+			t.Fatalf("timer due at %s still pending after Close", timer.at)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package pkgwatcher
+
+import (
+	"go/build"
+	"os"
+	"strings"
+)
+
+// Create a build context matching what go build would use based on the
+// GOOS, GOARCH, CGO_ENABLED and GOFLAGS environment variables. Build
+// tags are taken from a -tags flag in GOFLAGS.
+func ContextFromEnv() build.Context {
+	ctxt := build.Default
+	if v := os.Getenv("GOOS"); v != "" {
+		ctxt.GOOS = v
+	}
+	if v := os.Getenv("GOARCH"); v != "" {
+		ctxt.GOARCH = v
+	}
+	switch os.Getenv("CGO_ENABLED") {
+	case "0":
+		ctxt.CgoEnabled = false
+	case "1":
+		ctxt.CgoEnabled = true
+	}
+	ctxt.BuildTags = append(ctxt.BuildTags, goflagsTags(os.Getenv("GOFLAGS"))...)
+	return ctxt
+}
+
+// Extract the build tags from a GOFLAGS value.
+func goflagsTags(goflags string) []string {
+	var tags []string
+	fields := strings.Fields(goflags)
+	for i, f := range fields {
+		f = strings.TrimPrefix(f, "-")
+		f = strings.TrimPrefix(f, "-")
+		var value string
+		switch {
+		case strings.HasPrefix(f, "tags="):
+			value = strings.TrimPrefix(f, "tags=")
+		case f == "tags" && i+1 < len(fields):
+			value = fields[i+1]
+		default:
+			continue
+		}
+		for _, tag := range strings.FieldsFunc(value, func(r rune) bool {
+			return r == ',' || r == ' '
+		}) {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+// Set the build context used to resolve packages, overriding the one
+// derived from the environment. Applies to packages resolved afterwards.
+func (w *Watcher) SetContext(ctxt build.Context) {
+	w.mu.Lock()
+	w.context = ctxt
+	w.mu.Unlock()
+	w.analysis.cache.invalidateAll()
+}
+
+// Create a build context for the js/wasm target, for projects compiling
+// Go to WebAssembly whose dependencies differ from those of the host.
+func WasmContext() build.Context {
+	ctxt := ContextFromEnv()
+	ctxt.GOOS = "js"
+	ctxt.GOARCH = "wasm"
+	ctxt.CgoEnabled = false
+	return ctxt
+}
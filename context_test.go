@@ -0,0 +1,65 @@
+package pkgwatcher
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+// A package with a js/wasm implementation using syscall/js and a host
+// implementation, each with its own dependency.
+var frontendFiles = map[string]string{
+	"front/front.go": "package front\n",
+	"front/dom_js.go": `//go:build js && wasm
+
+package front
+
+import (
+	_ "jsdep"
+	_ "syscall/js"
+)
+`,
+	"front/dom_other.go": `//go:build !js
+
+package front
+
+import _ "hostdep"
+`,
+	"jsdep/jsdep.go":     "package jsdep\n",
+	"hostdep/hostdep.go": "package hostdep\n",
+}
+
+func TestWasmContextResolvesConditionalDeps(t *testing.T) {
+	cases := []struct {
+		name       string
+		wasm       bool
+		resolved   []string
+		unresolved []string
+	}{
+		{"wasm", true, []string{"front", "jsdep", "syscall/js"}, []string{"hostdep"}},
+		{"host", false, []string{"front", "hostdep"}, []string{"jsdep", "syscall/js"}},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			gopath := tempGopath(t, frontendFiles)
+			w, _ := testWatcher(t, gopath, filepath.Join(gopath, "src", "front"))
+			if c.wasm {
+				ctxt := WasmContext()
+				ctxt.GOPATH = gopath
+				w.SetContext(ctxt)
+			}
+			w.WatchImportPath("front", false)
+			w.mu.RLock()
+			defer w.mu.RUnlock()
+			for _, path := range c.resolved {
+				if w.Packages[path] == nil {
+					t.Errorf("%s not resolved", path)
+				}
+			}
+			for _, path := range c.unresolved {
+				if w.Packages[path] != nil {
+					t.Errorf("%s resolved", path)
+				}
+			}
+		})
+	}
+}
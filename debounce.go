@@ -0,0 +1,108 @@
+package pkgwatcher
+
+import (
+	"sync"
+	"time"
+)
+
+// Coalesces bursts of events for the same file.
+type debouncer struct {
+	mu      sync.Mutex
+	delay   time.Duration
+	pending map[string]*pendingEvent // by file name
+	fast    map[string]bool          // low latency import paths
+}
+
+// The latest event for a file waiting for the delay to pass.
+type pendingEvent struct {
+	ev    *Event
+	timer Timer
+}
+
+// Send an event to consumers, waiting for the debounce delay to pass
+// without further events for the same file.
+func (w *Watcher) emit(ev *Event) {
+	d := &w.debouncer
+	d.mu.Lock()
+	if d.delay <= 0 || ev.FileEvent == nil || d.lowLatency(ev) {
+		if ev.FileEvent != nil {
+			if p := d.pending[ev.Name]; p != nil {
+				p.timer.Stop()
+				delete(d.pending, ev.Name)
+				p.ev.traceEnd("superseded")
+			}
+		}
+		d.mu.Unlock()
+		w.send(ev)
+		return
+	}
+	defer d.mu.Unlock()
+	ev.traceQueued()
+	if p := d.pending[ev.Name]; p != nil {
+		p.ev.traceEnd("superseded")
+		p.ev = ev
+		p.timer.Reset(d.delay)
+		return
+	}
+	if d.pending == nil {
+		d.pending = make(map[string]*pendingEvent)
+	}
+	p := &pendingEvent{ev: ev}
+	p.timer = w.afterFunc(d.delay, func() {
+		d.mu.Lock()
+		if d.pending[ev.Name] != p {
+			d.mu.Unlock()
+			return // already sent
+		}
+		delete(d.pending, ev.Name)
+		latest := p.ev
+		d.mu.Unlock()
+		w.send(latest)
+	})
+	d.pending[ev.Name] = p
+}
+
+// Deliver events for the packages with the given import paths as soon as
+// they arrive, bypassing the debounce delay. This replaces any previously
+// set low latency packages; call it with no arguments to debounce all
+// packages again.
+func (w *Watcher) SetLowLatency(importPaths ...string) {
+	d := &w.debouncer
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.fast = lowLatencySet(importPaths)
+}
+
+func lowLatencySet(importPaths []string) map[string]bool {
+	if len(importPaths) == 0 {
+		return nil
+	}
+	set := make(map[string]bool, len(importPaths))
+	for _, path := range importPaths {
+		set[path] = true
+	}
+	return set
+}
+
+// Check if an event is for a low latency package. Must be called with the
+// lock held.
+func (d *debouncer) lowLatency(ev *Event) bool {
+	return ev.Package != nil && d.fast[ev.Package.ImportPath]
+}
+
+// Send an event on the Event channel unless the Watcher is closed.
+func (w *Watcher) send(ev *Event) {
+	defer ev.traceEnd("sent")
+	defer ev.traceRegion("send")()
+	if w.trySend(ev) {
+		return
+	}
+	w.setBlocked(true)
+	defer w.setBlocked(false)
+	// recorded first so the event is in the history once received
+	w.history.add(ev)
+	select {
+	case w.Event <- ev:
+	case <-w.done:
+	}
+}
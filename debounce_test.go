@@ -0,0 +1,109 @@
+package pkgwatcher
+
+import (
+	"github.com/howeyc/fsnotify"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// Inject an event for a file with the given Op.
+func injectFile(w *Watcher, name string, op Op) {
+	w.Inject(&Event{FileEvent: &fsnotify.FileEvent{Name: name}, Op: op})
+}
+
+// Wait for the debouncer to hold an event with the given Op for a file.
+func waitPending(t testing.TB, w *Watcher, name string, op Op) {
+	waitFor(t, "the debounced "+op.String()+" event", func() bool {
+		d := &w.debouncer
+		d.mu.Lock()
+		defer d.mu.Unlock()
+		p := d.pending[name]
+		return p != nil && p.ev.Op == op
+	})
+}
+
+func TestDebounceDeliversLatestEvent(t *testing.T) {
+	c := newFakeClock()
+	gopath := tempGopath(t, map[string]string{"p/p.go": "package p\n"})
+	dir := filepath.Join(gopath, "src", "p")
+	w, _ := testWatcher(t, gopath, dir, WithClock(c), WithDebounce(100*time.Millisecond))
+	w.WatchImportPath("p", false)
+	name := filepath.Join(dir, "p.go")
+
+	injectFile(w, name, OpCreate)
+	waitPending(t, w, name, OpCreate)
+	c.Advance(60 * time.Millisecond)
+	injectFile(w, name, OpModify)
+	waitPending(t, w, name, OpModify)
+	// the second event restarted the delay
+	c.Advance(60 * time.Millisecond)
+	noEvent(t, w)
+	c.Advance(40 * time.Millisecond)
+	if ev := nextEvent(t, w); ev.Name != name || ev.Op != OpModify {
+		t.Fatalf("got %s %s, want %s %s", ev.Op, ev.Name, OpModify, name)
+	}
+	noEvent(t, w)
+}
+
+func TestDebounceKeepsFilesApart(t *testing.T) {
+	c := newFakeClock()
+	gopath := tempGopath(t, map[string]string{
+		"p/a.go": "package p\n",
+		"p/b.go": "package p\n",
+	})
+	dir := filepath.Join(gopath, "src", "p")
+	w, _ := testWatcher(t, gopath, dir, WithClock(c), WithDebounce(100*time.Millisecond))
+	w.WatchImportPath("p", false)
+	a, b := filepath.Join(dir, "a.go"), filepath.Join(dir, "b.go")
+
+	injectFile(w, a, OpModify)
+	waitPending(t, w, a, OpModify)
+	c.Advance(50 * time.Millisecond)
+	injectFile(w, b, OpModify)
+	waitPending(t, w, b, OpModify)
+	c.Advance(50 * time.Millisecond)
+	if ev := nextEvent(t, w); ev.Name != a {
+		t.Fatalf("got event for %s, want %s", ev.Name, a)
+	}
+	noEvent(t, w)
+	c.Advance(50 * time.Millisecond)
+	if ev := nextEvent(t, w); ev.Name != b {
+		t.Fatalf("got event for %s, want %s", ev.Name, b)
+	}
+}
+
+func TestLowLatencyBypassesDebounce(t *testing.T) {
+	c := newFakeClock()
+	gopath := tempGopath(t, map[string]string{
+		"p/p.go": "package p\n\nimport _ \"q\"\n",
+		"q/q.go": "package q\n",
+	})
+	w, _ := testWatcher(t, gopath, filepath.Join(gopath, "src", "p"),
+		WithClock(c), WithDebounce(time.Hour), WithLowLatency("p"))
+	w.WatchImportPath("p", false)
+	p := filepath.Join(gopath, "src", "p", "p.go")
+	q := filepath.Join(gopath, "src", "q", "q.go")
+
+	injectFile(w, q, OpModify)
+	injectFile(w, p, OpModify)
+	if ev := nextEvent(t, w); ev.Name != p {
+		t.Fatalf("got event for %s, want %s", ev.Name, p)
+	}
+	waitPending(t, w, q, OpModify)
+	noEvent(t, w)
+	c.Advance(time.Hour)
+	if ev := nextEvent(t, w); ev.Name != q {
+		t.Fatalf("got event for %s, want %s", ev.Name, q)
+	}
+
+	// debounce everything again
+	w.SetLowLatency()
+	injectFile(w, p, OpModify)
+	waitPending(t, w, p, OpModify)
+	noEvent(t, w)
+	c.Advance(time.Hour)
+	if ev := nextEvent(t, w); ev.Name != p {
+		t.Fatalf("got event for %s, want %s", ev.Name, p)
+	}
+}
@@ -0,0 +1,204 @@
+package pkgwatcher
+
+import (
+	"go/build"
+	"go/types"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// Checks changed packages for build errors and API changes.
+type diagnoser struct {
+	mu      sync.Mutex
+	enabled bool
+	api     bool
+	apis    map[string]map[string]string // import path -> symbol -> signature
+	running map[string]bool              // by pkg.Dir, true if another check is wanted
+}
+
+// The exported symbols that differ between two versions of a package.
+type APIDiff struct {
+	Added   []string
+	Removed []string
+	Changed []string
+}
+
+// Enable or disable diagnostics. When enabled, a package is parsed and
+// type checked after a change to one of its Go files, and a
+// PackageDiagnostics event is emitted with the errors found, if any.
+func (w *Watcher) SetDiagnostics(enabled bool) {
+	w.diagnoser.mu.Lock()
+	defer w.diagnoser.mu.Unlock()
+	w.diagnoser.enabled = enabled
+}
+
+// Enable or disable API tracking. When enabled, the exported API of
+// watched packages is recorded, and a package is type checked after a
+// change to one of its Go files, emitting an APIChanged event listing
+// the exported symbols that were added, removed or changed. Dependents
+// only need rebuilding on such events. Applies to packages watched
+// afterwards.
+func (w *Watcher) SetAPITracking(enabled bool) {
+	w.diagnoser.mu.Lock()
+	defer w.diagnoser.mu.Unlock()
+	w.diagnoser.api = enabled
+	if enabled && w.diagnoser.apis == nil {
+		w.diagnoser.apis = make(map[string]map[string]string)
+	}
+}
+
+// Record the API of a package if API tracking is enabled.
+func (w *Watcher) recordAPI(pkg *build.Package) {
+	d := &w.diagnoser
+	d.mu.Lock()
+	_, known := d.apis[pkg.ImportPath]
+	track := d.api && !known && !pkg.Goroot
+	d.mu.Unlock()
+	if !track {
+		return
+	}
+	tpkg, _ := w.checkPackage(pkg)
+	if tpkg == nil {
+		return
+	}
+	d.mu.Lock()
+	d.apis[pkg.ImportPath] = exportedAPI(tpkg)
+	d.mu.Unlock()
+}
+
+// Check a package in the background after a change to the given file.
+// Changes arriving during a check cause one more check once it is done.
+func (w *Watcher) diagnose(pkg *build.Package, file string) {
+	if filepath.Ext(file) != ".go" {
+		return
+	}
+	d := &w.diagnoser
+	d.mu.Lock()
+	if !d.enabled && !d.api {
+		d.mu.Unlock()
+		return
+	}
+	if _, ok := d.running[pkg.Dir]; ok {
+		d.running[pkg.Dir] = true
+		d.mu.Unlock()
+		return
+	}
+	if d.running == nil {
+		d.running = make(map[string]bool)
+	}
+	d.running[pkg.Dir] = false
+	d.mu.Unlock()
+
+	w.spawn(func() {
+		for {
+			if current := w.dirPackage(pkg.Dir); current != nil {
+				pkg = current
+			}
+			// dependents must see the changed package
+			w.analysis.cache.invalidate(pkg.Dir)
+			var tpkg *types.Package
+			var errs []error
+			w.protect("diagnostics", func() { tpkg, errs = w.checkPackage(pkg) })
+			d.mu.Lock()
+			diagnostics := d.enabled
+			var diff *APIDiff
+			if d.api && tpkg != nil && len(errs) == 0 {
+				api := exportedAPI(tpkg)
+				if old, ok := d.apis[pkg.ImportPath]; ok {
+					diff = diffAPI(old, api)
+				}
+				d.apis[pkg.ImportPath] = api
+			}
+			d.mu.Unlock()
+			if diagnostics {
+				w.sendPackageEvent(&PackageEvent{
+					Kind:    PackageDiagnostics,
+					Package: pkg,
+					Errors:  errs,
+					Time:    w.clock.Now(),
+				})
+				if len(errs) > 0 {
+					w.fail(BuildFailed, pkg, errs...)
+				}
+			}
+			if diff != nil {
+				w.sendPackageEvent(&PackageEvent{
+					Kind:    APIChanged,
+					Package: pkg,
+					API:     diff,
+					Time:    w.clock.Now(),
+				})
+			}
+			d.mu.Lock()
+			if !d.running[pkg.Dir] {
+				delete(d.running, pkg.Dir)
+				d.mu.Unlock()
+				return
+			}
+			d.running[pkg.Dir] = false
+			d.mu.Unlock()
+		}
+	})
+}
+
+// Parse and type check a package, returning the checked package and the
+// errors found. Type errors are only reported if all files parsed.
+func (w *Watcher) checkPackage(pkg *build.Package) (*types.Package, []error) {
+	var names []string
+	for _, name := range pkg.GoFiles {
+		names = append(names, filepath.Join(pkg.Dir, name))
+	}
+	r := w.resolverFor(RootOptions{})
+	return w.analysis.cache.check(r, pkg.ImportPath, names, &types.Info{})
+}
+
+// The exported symbols of a package with their signatures, including
+// the exported methods of exported types.
+func exportedAPI(pkg *types.Package) map[string]string {
+	api := make(map[string]string)
+	qualifier := types.RelativeTo(pkg)
+	scope := pkg.Scope()
+	for _, name := range scope.Names() {
+		obj := scope.Lookup(name)
+		if !obj.Exported() {
+			continue
+		}
+		api[name] = types.ObjectString(obj, qualifier)
+		if _, ok := obj.(*types.TypeName); !ok {
+			continue
+		}
+		mset := types.NewMethodSet(types.NewPointer(obj.Type()))
+		for i := 0; i < mset.Len(); i++ {
+			m := mset.At(i).Obj()
+			if m.Exported() {
+				api[name+"."+m.Name()] = types.ObjectString(m, qualifier)
+			}
+		}
+	}
+	return api
+}
+
+// Compare two APIs, returning nil if they are the same.
+func diffAPI(old, cur map[string]string) *APIDiff {
+	var diff APIDiff
+	for name, sig := range cur {
+		if prev, ok := old[name]; !ok {
+			diff.Added = append(diff.Added, name)
+		} else if prev != sig {
+			diff.Changed = append(diff.Changed, name)
+		}
+	}
+	for name := range old {
+		if _, ok := cur[name]; !ok {
+			diff.Removed = append(diff.Removed, name)
+		}
+	}
+	if len(diff.Added)+len(diff.Removed)+len(diff.Changed) == 0 {
+		return nil
+	}
+	sort.Strings(diff.Added)
+	sort.Strings(diff.Removed)
+	sort.Strings(diff.Changed)
+	return &diff
+}
@@ -0,0 +1,74 @@
+package pkgwatcher
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Watch package p importing q with diagnostics enabled.
+func diagnosticsWatcher(t *testing.T) (*Watcher, string) {
+	gopath := tempGopath(t, map[string]string{
+		"p/p.go": "package p\n\nimport \"q\"\n\nvar V = q.F()\n",
+		"q/q.go": "package q\n\nfunc F() int { return 1 }\n",
+	})
+	dir := filepath.Join(gopath, "src", "p")
+	w, _ := testWatcher(t, gopath, dir)
+	w.SetDiagnostics(true)
+	w.WatchImportPath("p", false)
+	return w, gopath
+}
+
+// Change a file and wait for the diagnostics of its package.
+func diagnoseChange(t *testing.T, w *Watcher, name, content string) *PackageEvent {
+	if err := os.WriteFile(name, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	injectFile(w, name, OpModify)
+	nextEvent(t, w)
+	for {
+		pe := nextPackageEvent(t, w)
+		if pe.Kind == PackageDiagnostics {
+			return pe
+		}
+	}
+}
+
+func TestDiagnosticsUseWatcherContext(t *testing.T) {
+	w, gopath := diagnosticsWatcher(t)
+	name := filepath.Join(gopath, "src", "p", "p.go")
+	pe := diagnoseChange(t, w, name, "package p\n\nimport \"q\"\n\nvar V = q.F() + 1\n")
+	if pe.Package.ImportPath != "p" || len(pe.Errors) != 0 {
+		t.Fatalf("got diagnostics %v for %s, want none for p", pe.Errors, pe.Package.ImportPath)
+	}
+}
+
+func TestDiagnosticsSeeChangedDependency(t *testing.T) {
+	w, gopath := diagnosticsWatcher(t)
+	p := filepath.Join(gopath, "src", "p", "p.go")
+	diagnoseChange(t, w, p, "package p\n\nimport \"q\"\n\nvar V = q.F() + 1\n")
+	q := filepath.Join(gopath, "src", "q", "q.go")
+	if pe := diagnoseChange(t, w, q, "package q\n\nfunc G() int { return 1 }\n"); len(pe.Errors) != 0 {
+		t.Fatalf("got diagnostics %v for q, want none", pe.Errors)
+	}
+	pe := diagnoseChange(t, w, p, "package p\n\nimport \"q\"\n\nvar V = q.F() + 2\n")
+	if len(pe.Errors) == 0 || !strings.Contains(pe.Errors[0].Error(), "F") {
+		t.Fatalf("got diagnostics %v for p, want the missing q.F", pe.Errors)
+	}
+}
+
+func TestDiagnosticsDoNotBlockEvents(t *testing.T) {
+	w, gopath := diagnosticsWatcher(t)
+	name := filepath.Join(gopath, "src", "p", "p.go")
+	// a long running check
+	w.analysis.cache.checkMu.Lock()
+	for i := 0; i < 3; i++ {
+		injectFile(w, name, OpModify)
+		nextEvent(t, w)
+	}
+	w.analysis.cache.checkMu.Unlock()
+	if pe := nextPackageEvent(t, w); pe.Kind != PackageDiagnostics || len(pe.Errors) != 0 {
+		t.Fatalf("got %s with %v, want PackageDiagnostics without errors", pe.Kind, pe.Errors)
+	}
+}
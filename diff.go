@@ -0,0 +1,179 @@
+package pkgwatcher
+
+import (
+	"go/build"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// The difference between the watched set and a fresh resolution.
+type ResolutionDiff struct {
+	AddedPackages   []string // import paths that would be added
+	RemovedPackages []string // import paths that would be removed
+	AddedDirs       []string // directories that would be added
+	RemovedDirs     []string // directories that would be removed
+	AddedFiles      []string // files that would be added, see SetFileWatches
+	RemovedFiles    []string // files that would be removed
+}
+
+// Resolve the watched roots afresh, without registering any watches,
+// and report how the result differs from what is currently watched.
+// Useful for debugging stale watch state.
+func (w *Watcher) DiffResolution() ResolutionDiff {
+	fresh := w.fork()
+	w.mu.RLock()
+	roots := make(map[string]RootOptions, len(w.roots))
+	for path, opts := range w.roots {
+		roots[path] = opts
+	}
+	mainFile := w.mainFile
+	w.mu.RUnlock()
+	for path, opts := range roots {
+		fresh.resolve(path, false, opts)
+	}
+	if mainFile != "" {
+		fresh.resolveMainFile(mainFile)
+	}
+
+	freshDirs := make(map[string]bool)
+	freshFiles := make(map[string]bool)
+	for _, pkg := range fresh.Packages {
+		dirs, files := fresh.watchedPaths(pkg)
+		for _, dir := range dirs {
+			freshDirs[dir] = true
+		}
+		for _, file := range files {
+			freshFiles[file] = true
+		}
+	}
+
+	var diff ResolutionDiff
+	w.mu.RLock()
+	for path := range fresh.Packages {
+		if w.Packages[path] == nil {
+			diff.AddedPackages = append(diff.AddedPackages, path)
+		}
+	}
+	for path := range w.Packages {
+		if fresh.Packages[path] == nil {
+			diff.RemovedPackages = append(diff.RemovedPackages, path)
+		}
+	}
+	for dir := range freshDirs {
+		if !w.watchedDirectories[dir] {
+			diff.AddedDirs = append(diff.AddedDirs, dir)
+		}
+	}
+	for dir := range w.watchedDirectories {
+		if !freshDirs[dir] {
+			diff.RemovedDirs = append(diff.RemovedDirs, dir)
+		}
+	}
+	for file := range freshFiles {
+		if !w.watchedFiles[file] {
+			diff.AddedFiles = append(diff.AddedFiles, file)
+		}
+	}
+	for file := range w.watchedFiles {
+		if !freshFiles[file] {
+			diff.RemovedFiles = append(diff.RemovedFiles, file)
+		}
+	}
+	w.mu.RUnlock()
+	sort.Strings(diff.AddedPackages)
+	sort.Strings(diff.RemovedPackages)
+	sort.Strings(diff.AddedDirs)
+	sort.Strings(diff.RemovedDirs)
+	sort.Strings(diff.AddedFiles)
+	sort.Strings(diff.RemovedFiles)
+	return diff
+}
+
+// Create an unstarted Watcher with the same configuration, reporting
+// errors on the same channel.
+func (w *Watcher) fork() *Watcher {
+	fresh := newWatcher(w.workingDirectory)
+	fresh.Error = w.Error
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	fresh.errorHandler = w.errorHandler
+	fresh.errorTimeout = w.errorTimeout
+	fresh.errorsUnread = w.errorsUnread
+	fresh.resolver = w.resolver
+	fresh.mode = w.mode
+	fresh.context = w.context
+	fresh.assetDirs = w.assetDirs
+	fresh.skipDirs = w.skipDirs
+	fresh.hidden = w.hidden
+	fresh.skipGoroot = w.skipGoroot
+	fresh.clock = w.clock
+	fresh.hiddenExceptions = w.hiddenExceptions
+	fresh.fileWatches = w.fileWatches
+	fresh.reducedDeps = w.reducedDeps
+	fresh.rootTestData = w.rootTestData
+	fresh.includePrefixes = w.includePrefixes
+	fresh.excludePrefixes = w.excludePrefixes
+	for _, t := range w.targets {
+		fresh.targets = append(fresh.targets, &target{
+			name:     t.name,
+			context:  t.context,
+			packages: make(map[string]bool),
+		})
+	}
+	return fresh
+}
+
+// The directories and files that watchPackage would watch for a package,
+// ignoring the watch budget.
+func (w *Watcher) watchedPaths(pkg *build.Package) (dirs, files []string) {
+	if w.skipReason(pkg) != "" {
+		return nil, nil
+	}
+	if w.usesFileWatches() || pkg.ImportPath == MainImportPath {
+		for _, name := range sourceFiles(pkg) {
+			files = append(files, filepath.Join(pkg.Dir, name))
+		}
+		return nil, files
+	}
+	if w.isReduced(pkg) {
+		return []string{pkg.Dir}, nil
+	}
+	dirs = w.packageDirs(pkg.Dir)
+	w.mu.RLock()
+	testData := w.rootTestData
+	w.mu.RUnlock()
+	if testData && w.isRoot(pkg.ImportPath) {
+		dirs = append(dirs, w.packageDirs(filepath.Join(pkg.Dir, "testdata"))...)
+	}
+	return dirs, nil
+}
+
+// The directories that watching a package directory would cover,
+// including its asset directories.
+func (w *Watcher) packageDirs(dir string) []string {
+	var dirs []string
+	if info, err := os.Lstat(dir); err != nil || !info.IsDir() || w.skipDir(info) {
+		return nil
+	}
+	filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || !info.IsDir() {
+			return nil
+		}
+		if path != dir && (w.skipDir(info) || w.skipName(info.Name())) {
+			return filepath.SkipDir
+		}
+		dirs = append(dirs, path)
+		return nil
+	})
+	w.mu.RLock()
+	names := w.assetDirs
+	w.mu.RUnlock()
+	for _, name := range names {
+		path := filepath.Join(dir, name)
+		if info, err := os.Stat(path); err == nil && info.IsDir() {
+			dirs = append(dirs, w.packageDirs(path)...)
+		}
+	}
+	return dirs
+}
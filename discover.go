@@ -0,0 +1,83 @@
+package pkgwatcher
+
+import (
+	"go/build"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Find the Go packages in the source tree below rootDir and return their
+// import paths. Import paths come from the nearest enclosing go.mod, or
+// from the GOPATH for directories outside any module; packages with
+// neither are skipped. Directories for which filter returns false are
+// skipped along with their subdirectories. A nil filter includes all.
+func DiscoverPackages(rootDir string, filter func(dir string) bool) ([]string, error) {
+	root, err := filepath.Abs(rootDir)
+	if err != nil {
+		return nil, err
+	}
+	modules := make(map[string]*Module)
+	var paths []string
+	err = filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			return nil
+		}
+		if path != root && skipSourceDir(info.Name()) {
+			return filepath.SkipDir
+		}
+		if filter != nil && !filter(path) {
+			return filepath.SkipDir
+		}
+		if !hasGoFiles(path) {
+			return nil
+		}
+		if importPath, ok := dirImportPath(path, modules); ok {
+			paths = append(paths, importPath)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
+
+// The import path of a directory, using and filling the cache of module
+// lookups.
+func dirImportPath(dir string, modules map[string]*Module) (string, bool) {
+	for d := dir; ; d = filepath.Dir(d) {
+		mod, ok := modules[d]
+		if !ok {
+			mod = readModule(d)
+			modules[d] = mod
+		}
+		if mod != nil {
+			rel, err := filepath.Rel(mod.Dir, dir)
+			if err != nil {
+				return "", false
+			}
+			if rel == "." {
+				return mod.Path, true
+			}
+			return mod.Path + "/" + filepath.ToSlash(rel), true
+		}
+		if parent := filepath.Dir(d); parent == d {
+			break
+		}
+	}
+	for _, src := range build.Default.SrcDirs() {
+		rel, err := filepath.Rel(src, dir)
+		if err != nil || rel == "." || rel == ".." ||
+			strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			continue
+		}
+		return filepath.ToSlash(rel), true
+	}
+	return "", false
+}
@@ -0,0 +1,88 @@
+package pkgwatcher
+
+import (
+	"log"
+	"time"
+)
+
+// How long an error waits for a reader of the Error channel before it
+// is logged instead.
+const DefaultErrorTimeout = 10 * time.Second
+
+// Set a function to handle errors instead of sending them on the Error
+// channel. Useful for users not interested in draining the channel.
+func (w *Watcher) SetErrorHandler(h func(error)) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.errorHandler = h
+}
+
+// Set how long an error waits for a reader of the Error channel before
+// it is logged instead. Once an error timed out, further errors are only
+// sent if a reader is waiting, and logged right away otherwise, until
+// one is received again.
+func (w *Watcher) SetErrorTimeout(d time.Duration) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.errorTimeout = d
+}
+
+// Report an error to the handler if one is set, or on the Error channel
+// falling back to logging it if no one is reading.
+func (w *Watcher) reportError(err error) {
+	w.mu.RLock()
+	h := w.errorHandler
+	timeout := w.errorTimeout
+	unread := w.errorsUnread
+	w.mu.RUnlock()
+	if h != nil {
+		h(err)
+		return
+	}
+	if unread {
+		select {
+		case w.Error <- err:
+			w.setErrorsUnread(false)
+		default:
+			log.Printf("pkgwatcher: %s", err)
+		}
+		return
+	}
+	select {
+	case w.Error <- err:
+	case <-w.clock.After(timeout):
+		w.setErrorsUnread(true)
+		log.Printf("pkgwatcher: %s", err)
+	case <-w.done:
+		log.Printf("pkgwatcher: %s", err)
+	}
+}
+
+// Note whether the last error sent on the Error channel timed out.
+func (w *Watcher) setErrorsUnread(unread bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.errorsUnread = unread
+}
+
+// Forward errors from the underlying watcher until the Watcher is
+// closed.
+func (w *Watcher) forwardErrors() {
+	errs := w.backend.Errors()
+	for {
+		select {
+		case err, ok := <-errs:
+			if !ok {
+				errs = nil
+				continue
+			}
+			w.mu.Lock()
+			w.backendErr = err
+			w.mu.Unlock()
+			w.reportError(err)
+			w.fail(WatchFailed, nil, err)
+		case <-w.done:
+			return
+		}
+	}
+}
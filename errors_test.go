@@ -0,0 +1,74 @@
+package pkgwatcher
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+// Report an error in the background, returning a channel closed once
+// reportError returns.
+func reportInBackground(w *Watcher, err error) chan bool {
+	reported := make(chan bool)
+	go func() {
+		w.reportError(err)
+		close(reported)
+	}()
+	return reported
+}
+
+// Wait for a channel to be closed or fail after a while.
+func waitClosed(t *testing.T, ch chan bool, what string) {
+	select {
+	case <-ch:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("timed out waiting for %s", what)
+	}
+}
+
+func TestUnreadErrorsAreLogged(t *testing.T) {
+	c := newFakeClock()
+	w, _ := testWatcher(t, t.TempDir(), t.TempDir(), WithClock(c))
+	w.SetErrorHandler(nil)
+	w.SetErrorTimeout(time.Minute)
+
+	first := reportInBackground(w, fmt.Errorf("first"))
+	// the poller, the retrier and the error
+	c.blockUntil(t, 3)
+	c.Advance(time.Minute - 1)
+	select {
+	case <-first:
+		t.Fatal("error logged before the timeout")
+	case <-time.After(50 * time.Millisecond):
+	}
+	c.Advance(1)
+	waitClosed(t, first, "the first error to be logged")
+
+	// further errors do not wait
+	for i := 0; i < 3; i++ {
+		waitClosed(t, reportInBackground(w, fmt.Errorf("error %d", i)), "an unread error")
+	}
+
+	// a reader gets errors again
+	received := make(chan error)
+	go func() { received <- <-w.Error }()
+	waitFor(t, "the reader", func() bool {
+		w.reportError(fmt.Errorf("read"))
+		w.mu.RLock()
+		defer w.mu.RUnlock()
+		return !w.errorsUnread
+	})
+	if err := <-received; err.Error() != "read" {
+		t.Fatalf("got error %q, want read", err)
+	}
+	second := reportInBackground(w, fmt.Errorf("second"))
+	select {
+	case <-second:
+		t.Fatal("error logged without waiting for a reader")
+	case <-time.After(50 * time.Millisecond):
+	}
+	if err := <-w.Error; err.Error() != "second" {
+		t.Fatalf("got error %q, want second", err)
+	}
+	waitClosed(t, second, "the second error to be sent")
+}
@@ -0,0 +1,59 @@
+package pkgwatcher
+
+import (
+	"fmt"
+	"go/build"
+	"time"
+)
+
+// The kind of a Failure.
+type FailureKind int
+
+const (
+	// A changed package no longer builds, see SetDiagnostics.
+	BuildFailed FailureKind = iota
+	// A path could not be watched or the backend reported an error.
+	WatchFailed
+	// A hook was killed after running for longer than its timeout, see
+	// SetHookTimeout.
+	HookTimedOut
+)
+
+func (k FailureKind) String() string {
+	switch k {
+	case BuildFailed:
+		return "BuildFailed"
+	case WatchFailed:
+		return "WatchFailed"
+	case HookTimedOut:
+		return "HookTimedOut"
+	}
+	return fmt.Sprintf("FailureKind(%d)", int(k))
+}
+
+// A failure worth alerting on, distinct from routine events.
+type Failure struct {
+	Kind    FailureKind
+	Package *build.Package // set for BuildFailed and HookTimedOut
+	Errors  []error
+	Time    time.Time
+}
+
+// Also deliver failures on the Failure channel, buffering up to n of
+// them. Failures are still reported through the usual channels.
+func WithFailures(n int) Option {
+	return func(w *Watcher) {
+		w.Failure = make(chan *Failure, n)
+	}
+}
+
+// Deliver a failure if the Failure channel is enabled.
+func (w *Watcher) fail(kind FailureKind, pkg *build.Package, errs ...error) {
+	if w.Failure == nil {
+		return
+	}
+	select {
+	case w.Failure <- &Failure{Kind: kind, Package: pkg, Errors: errs, Time: w.clock.Now()}:
+	case <-w.done:
+	}
+}
@@ -0,0 +1,61 @@
+package pkgwatcher
+
+import (
+	"encoding/json"
+	"go/build"
+	"io"
+	"path/filepath"
+	"sort"
+)
+
+// The source files of a watched package, in a form usable to generate
+// inputs for other build systems such as Bazel filegroups.
+type FileGroup struct {
+	ImportPath string   `json:"importPath"`
+	Dir        string   `json:"dir"`
+	Files      []string `json:"files"` // absolute and sorted
+}
+
+// The source files of the watched packages grouped per package and
+// sorted by import path, including test, cgo and assembly files.
+func (w *Watcher) FileGroups() []FileGroup {
+	var groups []FileGroup
+	for _, pkg := range w.PackageList() {
+		if w.skipReason(pkg) != "" {
+			continue
+		}
+		groups = append(groups, FileGroup{
+			ImportPath: pkg.ImportPath,
+			Dir:        pkg.Dir,
+			Files:      packageFiles(pkg),
+		})
+	}
+	return groups
+}
+
+// Write the FileGroups as a JSON array.
+func (w *Watcher) WriteFileGroups(out io.Writer) error {
+	groups := w.FileGroups()
+	if groups == nil {
+		groups = []FileGroup{}
+	}
+	enc := json.NewEncoder(out)
+	enc.SetIndent("", "  ")
+	return enc.Encode(groups)
+}
+
+// All the files of a package that are inputs to building or testing it.
+func packageFiles(pkg *build.Package) []string {
+	var files []string
+	for _, set := range [][]string{
+		pkg.GoFiles, pkg.CgoFiles, pkg.CFiles, pkg.CXXFiles, pkg.MFiles,
+		pkg.HFiles, pkg.FFiles, pkg.SFiles, pkg.SwigFiles,
+		pkg.SwigCXXFiles, pkg.SysoFiles, pkg.TestGoFiles, pkg.XTestGoFiles,
+	} {
+		for _, name := range set {
+			files = append(files, filepath.Join(pkg.Dir, name))
+		}
+	}
+	sort.Strings(files)
+	return files
+}
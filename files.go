@@ -0,0 +1,91 @@
+package pkgwatcher
+
+import (
+	"go/build"
+	"path/filepath"
+	"sort"
+)
+
+// Enable or disable FileAdded and FileRemoved events, emitted when
+// files are added to or removed from the source file sets of a package.
+func (w *Watcher) SetTrackFiles(enabled bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.trackFiles = enabled
+}
+
+// Enable or disable PackageAdded events, emitted as packages are added
+// to the watch set during resolution, PackageSkipped events for the
+// packages that are not watched, and PackageRemoved events for packages
+// whose directory is gone. The PackageEvent channel must be drained
+// while resolving once enabled.
+func (w *Watcher) SetTrackPackages(enabled bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.trackPackages = enabled
+}
+
+// Watch for packages added to the watch set.
+func WithTrackPackages() Option {
+	return func(w *Watcher) {
+		w.trackPackages = true
+	}
+}
+
+// Re-import a package after a file in its directory was created or
+// removed, emitting events for the changes in its source files.
+func (w *Watcher) refreshFiles(pkg *build.Package, file string) {
+	w.mu.RLock()
+	track := w.trackFiles
+	w.mu.RUnlock()
+	if !track || filepath.Dir(file) != pkg.Dir {
+		return
+	}
+	updated, err := w.resolverFor(RootOptions{}).Import(pkg.ImportPath, pkg.Dir, build.AllowBinary)
+	if err != nil {
+		return // directory removal is handled separately
+	}
+
+	w.mu.Lock()
+	if w.DirPackages[pkg.Dir] == pkg {
+		w.DirPackages[pkg.Dir] = updated
+		w.dirs.set(pkg.Dir, updated)
+	}
+	if w.Packages[pkg.ImportPath] == pkg {
+		w.Packages[pkg.ImportPath] = updated
+	}
+	w.mu.Unlock()
+
+	old, cur := sourceFiles(pkg), sourceFiles(updated)
+	now := w.clock.Now()
+	for _, name := range cur {
+		if !contains(old, name) {
+			w.sendPackageEvent(&PackageEvent{
+				Kind: FileAdded, Package: updated, File: name, Time: now})
+		}
+	}
+	for _, name := range old {
+		if !contains(cur, name) {
+			w.sendPackageEvent(&PackageEvent{
+				Kind: FileRemoved, Package: updated, File: name, Time: now})
+		}
+	}
+}
+
+// The sorted Go source and test files of a package.
+func sourceFiles(pkg *build.Package) []string {
+	var files []string
+	for _, set := range [][]string{
+		pkg.GoFiles, pkg.CgoFiles, pkg.TestGoFiles, pkg.XTestGoFiles,
+	} {
+		files = append(files, set...)
+	}
+	sort.Strings(files)
+	return files
+}
+
+// Check if a sorted slice contains a string.
+func contains(sorted []string, s string) bool {
+	i := sort.SearchStrings(sorted, s)
+	return i < len(sorted) && sorted[i] == s
+}
@@ -0,0 +1,93 @@
+package pkgwatcher
+
+import (
+	"fmt"
+	"go/build"
+	"os"
+	"path/filepath"
+)
+
+// Enable or disable per file watching. When enabled, only the Go source
+// and test files of a package are watched instead of its directory tree,
+// so unrelated churn in the same directory, like benchmark output or
+// profiles, is never seen. Files replaced by a rename, as editors do on
+// save, are watched again; files added to a package are not noticed
+// until it is watched again. Applies to packages watched afterwards.
+func (w *Watcher) SetFileWatches(enabled bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.fileWatches = enabled
+}
+
+// Watch individual source files instead of directories.
+func WithFileWatches() Option {
+	return func(w *Watcher) {
+		w.fileWatches = true
+	}
+}
+
+// Check if per file watching is enabled.
+func (w *Watcher) usesFileWatches() bool {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return w.fileWatches
+}
+
+// Watch the source files of a package. Over the watch budget, the
+// package directory is polled instead.
+func (w *Watcher) watchFiles(pkg *build.Package) {
+	for _, name := range sourceFiles(pkg) {
+		w.watchFile(filepath.Join(pkg.Dir, name), pkg.Dir)
+	}
+}
+
+// Watch a single file.
+func (w *Watcher) watchFile(file, dir string) {
+	w.mu.Lock()
+	if w.watchedFiles[file] {
+		w.mu.Unlock()
+		return
+	}
+	if !w.poller.allows(w.watches) {
+		polled := w.watchedDirectories[dir]
+		w.watchedDirectories[dir] = true
+		w.mu.Unlock()
+		if !polled {
+			w.poller.add(dir)
+		}
+		return
+	}
+	if w.watchedFiles == nil {
+		w.watchedFiles = make(map[string]bool)
+	}
+	w.watchedFiles[file] = true
+	w.watches++
+	w.mu.Unlock()
+	if err := w.backend.Watch(file); err != nil {
+		err = fmt.Errorf("Error watching %s: %s", file, err)
+		w.reportError(err)
+		w.fail(WatchFailed, nil, err)
+	}
+}
+
+// Re-establish the watch for a watched file that was deleted or renamed,
+// if the file exists again.
+func (w *Watcher) rewatchFile(ev *Event) {
+	if ev.FileEvent == nil || !(ev.IsDelete() || ev.IsRename()) {
+		return
+	}
+	w.mu.Lock()
+	watched := w.watchedFiles[ev.Name]
+	if watched {
+		delete(w.watchedFiles, ev.Name)
+		w.watches--
+	}
+	w.mu.Unlock()
+	if !watched {
+		return
+	}
+	w.backend.RemoveWatch(ev.Name) // the watch may already be gone
+	if _, err := os.Stat(ev.Name); err == nil {
+		w.watchFile(ev.Name, filepath.Dir(ev.Name))
+	}
+}
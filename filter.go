@@ -0,0 +1,180 @@
+package pkgwatcher
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// A Filter decides if an event should be delivered.
+type Filter func(*Event) bool
+
+// Only deliver events accepted by the filter. A nil filter accepts all
+// events.
+func (w *Watcher) SetFilter(f Filter) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.filter = f
+}
+
+// Only deliver events accepted by the filter.
+func WithFilter(f Filter) Option {
+	return func(w *Watcher) {
+		w.filter = f
+	}
+}
+
+// Deliver events that only change file attributes, such as permissions.
+// They are frequent on some platforms and editors and almost never
+// matter, so they are dropped by default.
+func (w *Watcher) SetChmodEvents(enabled bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.chmodEvents = enabled
+}
+
+// Deliver events that only change file attributes.
+func WithChmodEvents() Option {
+	return func(w *Watcher) {
+		w.chmodEvents = true
+	}
+}
+
+// Check if an event only changes file attributes and those are dropped.
+func (w *Watcher) chmodOnly(ev *Event) bool {
+	if ev.FileEvent == nil || ev.Op != OpAttrib {
+		return false
+	}
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return !w.chmodEvents
+}
+
+// Check if an event passes the filter.
+func (w *Watcher) accept(ev *Event) bool {
+	w.mu.RLock()
+	f := w.filter
+	w.mu.RUnlock()
+	return f == nil || f(ev)
+}
+
+// Compile a filter expression made of space separated terms:
+//
+//	pkg:github.com/me/*   import path of the package, * matches anything
+//	op:write              one of create, write, delete, rename or chmod
+//	file:*.go             base name of the file
+//	dir:testdata          a directory anywhere in the file path
+//
+// A term prefixed with ! excludes matching events. Terms with the same
+// key match if any of them do, and an event must match every key.
+func ParseFilter(expr string) (Filter, error) {
+	include := make(map[string][]func(*Event) bool)
+	var exclude []func(*Event) bool
+	for _, term := range strings.Fields(expr) {
+		negate := strings.HasPrefix(term, "!")
+		term = strings.TrimPrefix(term, "!")
+		i := strings.Index(term, ":")
+		if i < 0 {
+			return nil, fmt.Errorf("Invalid filter term %q", term)
+		}
+		key, value := term[:i], term[i+1:]
+		match, err := filterTerm(key, value)
+		if err != nil {
+			return nil, err
+		}
+		if negate {
+			exclude = append(exclude, match)
+		} else {
+			include[key] = append(include[key], match)
+		}
+	}
+	return func(ev *Event) bool {
+		for _, match := range exclude {
+			if match(ev) {
+				return false
+			}
+		}
+	keys:
+		for _, matches := range include {
+			for _, match := range matches {
+				if match(ev) {
+					continue keys
+				}
+			}
+			return false
+		}
+		return true
+	}, nil
+}
+
+// Compile a single filter term.
+func filterTerm(key, value string) (func(*Event) bool, error) {
+	switch key {
+	case "pkg":
+		return func(ev *Event) bool {
+			return ev.Package != nil && globMatch(value, ev.Package.ImportPath)
+		}, nil
+	case "op":
+		var op func(*Event) bool
+		switch value {
+		case "create":
+			op = func(ev *Event) bool { return ev.IsCreate() }
+		case "write":
+			op = func(ev *Event) bool { return ev.IsModify() }
+		case "delete":
+			op = func(ev *Event) bool { return ev.IsDelete() }
+		case "rename":
+			op = func(ev *Event) bool { return ev.IsRename() }
+		case "chmod":
+			op = func(ev *Event) bool { return ev.IsAttrib() }
+		default:
+			return nil, fmt.Errorf("Invalid filter op %q", value)
+		}
+		return func(ev *Event) bool { return ev.FileEvent != nil && op(ev) }, nil
+	case "file":
+		if _, err := filepath.Match(value, ""); err != nil {
+			return nil, fmt.Errorf("Invalid filter pattern %q: %s", value, err)
+		}
+		return func(ev *Event) bool {
+			if ev.FileEvent == nil {
+				return false
+			}
+			ok, _ := filepath.Match(value, filepath.Base(ev.Name))
+			return ok
+		}, nil
+	case "dir":
+		return func(ev *Event) bool {
+			if ev.FileEvent == nil {
+				return false
+			}
+			dirs := strings.Split(filepath.ToSlash(filepath.Dir(ev.Name)), "/")
+			for _, dir := range dirs {
+				if ok, _ := filepath.Match(value, dir); ok {
+					return true
+				}
+			}
+			return false
+		}, nil
+	}
+	return nil, fmt.Errorf("Invalid filter key %q", key)
+}
+
+// Match a pattern where * matches any sequence of characters.
+func globMatch(pattern, s string) bool {
+	parts := strings.Split(pattern, "*")
+	if len(parts) == 1 {
+		return pattern == s
+	}
+	if !strings.HasPrefix(s, parts[0]) {
+		return false
+	}
+	s = s[len(parts[0]):]
+	for _, part := range parts[1 : len(parts)-1] {
+		i := strings.Index(s, part)
+		if i < 0 {
+			return false
+		}
+		s = s[i+len(part):]
+	}
+	return strings.HasSuffix(s, parts[len(parts)-1])
+}
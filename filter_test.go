@@ -0,0 +1,93 @@
+package pkgwatcher
+
+import (
+	"github.com/howeyc/fsnotify"
+	"go/build"
+	"path/filepath"
+	"testing"
+)
+
+// An event for a file in a package.
+func filterEvent(importPath, name string, op Op) *Event {
+	return &Event{
+		FileEvent: &fsnotify.FileEvent{Name: filepath.FromSlash(name)},
+		Op:        op,
+		Package:   &build.Package{ImportPath: importPath},
+	}
+}
+
+func TestParseFilter(t *testing.T) {
+	goWrite := filterEvent("github.com/me/app", "/src/app/main.go", OpModify)
+	testdata := filterEvent("github.com/me/app", "/src/app/testdata/in.txt", OpCreate)
+	other := filterEvent("example.com/lib", "/src/lib/lib.go", OpDelete|OpRename)
+	packageOnly := &Event{Package: &build.Package{ImportPath: "github.com/me/app"}}
+	cases := []struct {
+		expr  string
+		match []*Event
+		skip  []*Event
+	}{
+		{"", []*Event{goWrite, testdata, other, packageOnly}, nil},
+		{"pkg:github.com/me/*", []*Event{goWrite, testdata, packageOnly}, []*Event{other}},
+		{"pkg:*/lib", []*Event{other}, []*Event{goWrite}},
+		{"op:write", []*Event{goWrite}, []*Event{testdata, other, packageOnly}},
+		{"op:create op:rename", []*Event{testdata, other}, []*Event{goWrite}},
+		{"file:*.go", []*Event{goWrite, other}, []*Event{testdata, packageOnly}},
+		{"dir:testdata", []*Event{testdata}, []*Event{goWrite, other}},
+		{"!dir:testdata", []*Event{goWrite, other, packageOnly}, []*Event{testdata}},
+		{"pkg:github.com/me/* file:*.go", []*Event{goWrite}, []*Event{testdata, other}},
+		{"file:*.go !op:delete", []*Event{goWrite}, []*Event{other}},
+	}
+	for _, c := range cases {
+		f, err := ParseFilter(c.expr)
+		if err != nil {
+			t.Errorf("ParseFilter(%q) failed: %s", c.expr, err)
+			continue
+		}
+		for _, ev := range c.match {
+			if !f(ev) {
+				t.Errorf("%q does not match %+v", c.expr, ev)
+			}
+		}
+		for _, ev := range c.skip {
+			if f(ev) {
+				t.Errorf("%q matches %+v", c.expr, ev)
+			}
+		}
+	}
+}
+
+func TestParseFilterErrors(t *testing.T) {
+	for _, expr := range []string{
+		"app",
+		"!app",
+		"size:10",
+		"op:move",
+		"file:[",
+	} {
+		if _, err := ParseFilter(expr); err == nil {
+			t.Errorf("ParseFilter(%q) did not fail", expr)
+		}
+	}
+}
+
+func TestGlobMatch(t *testing.T) {
+	cases := []struct {
+		pattern, s string
+		match      bool
+	}{
+		{"a/b", "a/b", true},
+		{"a/b", "a/bc", false},
+		{"*", "", true},
+		{"a/*", "a/b/c", true},
+		{"*/c", "a/b/c", true},
+		{"a*c", "abc", true},
+		{"a*b*c", "aXbYc", true},
+		{"a*b*c", "acb", false},
+		{"ab*ba", "aba", false},
+	}
+	for _, c := range cases {
+		if got := globMatch(c.pattern, c.s); got != c.match {
+			t.Errorf("globMatch(%q, %q) = %v, want %v", c.pattern, c.s, got, c.match)
+		}
+	}
+}
@@ -0,0 +1,24 @@
+package pkgwatcher
+
+import (
+	"path/filepath"
+	"sort"
+)
+
+// The current watch set as LSP FileSystemWatcher glob patterns, one per
+// monitored directory matching the files directly inside it, and one per
+// individually watched file. Patterns use forward slashes as required by
+// the protocol.
+func (w *Watcher) LSPGlobs() []string {
+	w.mu.RLock()
+	globs := make([]string, 0, len(w.watchedDirectories)+len(w.watchedFiles))
+	for dir := range w.watchedDirectories {
+		globs = append(globs, filepath.ToSlash(dir)+"/*")
+	}
+	for file := range w.watchedFiles {
+		globs = append(globs, filepath.ToSlash(file))
+	}
+	w.mu.RUnlock()
+	sort.Strings(globs)
+	return globs
+}
@@ -0,0 +1,23 @@
+package pkgwatcher
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// The standard library import path for a file under GOROOT/src, based
+// on the directory containing it. Returns false for files elsewhere.
+func (w *Watcher) standardImportPath(file string) (string, bool) {
+	w.mu.RLock()
+	goroot := w.context.GOROOT
+	w.mu.RUnlock()
+	if goroot == "" {
+		return "", false
+	}
+	rel, err := filepath.Rel(filepath.Join(goroot, "src"), filepath.Dir(file))
+	if err != nil || rel == "." || rel == ".." ||
+		strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", false
+	}
+	return filepath.ToSlash(rel), true
+}
@@ -0,0 +1,135 @@
+package pkgwatcher
+
+import (
+	"go/build"
+	"path/filepath"
+	"sort"
+)
+
+// An import of one resolved package by another.
+type Edge struct {
+	From string // importing package
+	To   string // imported package
+}
+
+// The edges added to and removed from the import graph.
+type GraphDelta struct {
+	Added   []Edge
+	Removed []Edge
+}
+
+// Enable or disable GraphChanged events. When enabled, a package is
+// re-imported after a change to one of its Go files, newly imported
+// packages are resolved and watched, and a GraphChanged event carrying
+// the edges added and removed is emitted whenever resolution changes the
+// import graph.
+func (w *Watcher) SetTrackGraph(enabled bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.trackGraph = enabled
+}
+
+// Check if GraphChanged events are enabled.
+func (w *Watcher) tracksGraph() bool {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return w.trackGraph
+}
+
+// The edges between the resolved packages.
+func (w *Watcher) edges() map[Edge]bool {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	edges := make(map[Edge]bool)
+	for _, pkg := range w.Packages {
+		for _, path := range pkg.Imports {
+			if w.Packages[path] != nil {
+				edges[Edge{pkg.ImportPath, path}] = true
+			}
+		}
+	}
+	return edges
+}
+
+// Emit a GraphChanged event if the edges differ from before.
+func (w *Watcher) graphChanged(before map[Edge]bool) {
+	after := w.edges()
+	var delta GraphDelta
+	for e := range after {
+		if !before[e] {
+			delta.Added = append(delta.Added, e)
+		}
+	}
+	for e := range before {
+		if !after[e] {
+			delta.Removed = append(delta.Removed, e)
+		}
+	}
+	if len(delta.Added)+len(delta.Removed) == 0 {
+		return
+	}
+	sortEdges(delta.Added)
+	sortEdges(delta.Removed)
+	w.sendPackageEvent(&PackageEvent{
+		Kind:  GraphChanged,
+		Graph: &delta,
+		Time:  w.clock.Now(),
+	})
+}
+
+func sortEdges(edges []Edge) {
+	sort.Slice(edges, func(i, j int) bool {
+		if edges[i].From != edges[j].From {
+			return edges[i].From < edges[j].From
+		}
+		return edges[i].To < edges[j].To
+	})
+}
+
+// Re-import a package after a change to one of its Go files, resolving
+// and watching its new imports if they changed.
+func (w *Watcher) refreshGraph(pkg *build.Package, file string) {
+	if !w.tracksGraph() || filepath.Ext(file) != ".go" || filepath.Dir(file) != pkg.Dir {
+		return
+	}
+	current := w.dirPackage(pkg.Dir)
+	if current == nil || current.ImportPath != pkg.ImportPath {
+		return
+	}
+	resolver := w.resolverFor(RootOptions{})
+	updated, err := resolver.Import(pkg.ImportPath, pkg.Dir, build.AllowBinary)
+	if err != nil || equalStrings(current.Imports, updated.Imports) {
+		return
+	}
+	before := w.edges()
+	w.mu.Lock()
+	if w.DirPackages[pkg.Dir] == current {
+		w.DirPackages[pkg.Dir] = updated
+		w.dirs.set(pkg.Dir, updated)
+	}
+	if w.Packages[pkg.ImportPath] == current {
+		w.Packages[pkg.ImportPath] = updated
+	}
+	depth := w.depth[pkg.ImportPath]
+	w.mu.Unlock()
+	for _, path := range updated.Imports {
+		if w.follows(path) {
+			w.resolveWith(resolver, nil, path, depth+1, false, RootOptions{})
+		}
+	}
+	w.watchPackages()
+	w.graphChanged(before)
+}
+
+// Check if two sorted slices are equal.
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
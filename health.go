@@ -0,0 +1,47 @@
+package pkgwatcher
+
+import (
+	"time"
+)
+
+// The health of a Watcher, for supervision of long running processes.
+type Health struct {
+	Ready         bool          // the initial watch set has been established
+	Closed        bool          // the Watcher has been closed
+	Stalled       time.Duration // how long event delivery has been blocked
+	BackendClosed bool          // the Backend stopped delivering events
+	BackendError  error         // the last error reported by the Backend
+}
+
+// Get the current Health.
+func (w *Watcher) Health() Health {
+	var h Health
+	select {
+	case <-w.done:
+		h.Closed = true
+	default:
+	}
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	h.Ready = w.ready
+	h.BackendClosed = w.backendClosed
+	h.BackendError = w.backendErr
+	if w.blocked > 0 {
+		h.Stalled = w.clock.Now().Sub(w.blockedSince)
+	}
+	return h
+}
+
+// Note that delivery of an event started or stopped blocking.
+func (w *Watcher) setBlocked(blocked bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if blocked {
+		if w.blocked == 0 {
+			w.blockedSince = w.clock.Now()
+		}
+		w.blocked++
+	} else {
+		w.blocked--
+	}
+}
@@ -0,0 +1,51 @@
+// Package health serves health checks for a pkgwatcher Watcher, for
+// supervision by systemd or container orchestrators.
+package health
+
+import (
+	"encoding/json"
+	"github.com/daaku/go.pkgwatcher"
+	"net/http"
+	"time"
+)
+
+// How long event delivery may be blocked before the Watcher is
+// considered stalled.
+const DefaultMaxStall = time.Minute
+
+// Create a handler serving /healthz, which fails if the Watcher is
+// closed or stalled or its Backend stopped delivering events, and
+// /readyz, which also fails until the initial
+// watch set has been established. A maxStall of 0 uses DefaultMaxStall.
+func Handler(w *pkgwatcher.Watcher, maxStall time.Duration) http.Handler {
+	if maxStall == 0 {
+		maxStall = DefaultMaxStall
+	}
+	check := func(ready bool) http.HandlerFunc {
+		return func(rw http.ResponseWriter, r *http.Request) {
+			h := w.Health()
+			ok := !h.Closed && !h.BackendClosed && h.Stalled < maxStall &&
+				(!ready || h.Ready)
+			var backendError string
+			if h.BackendError != nil {
+				backendError = h.BackendError.Error()
+			}
+			rw.Header().Set("Content-Type", "application/json")
+			if !ok {
+				rw.WriteHeader(http.StatusServiceUnavailable)
+			}
+			json.NewEncoder(rw).Encode(struct {
+				OK           bool   `json:"ok"`
+				Ready        bool   `json:"ready"`
+				Closed       bool   `json:"closed"`
+				Stalled      string `json:"stalled"`
+				BackendAlive bool   `json:"backend_alive"`
+				BackendError string `json:"backend_error,omitempty"`
+			}{ok, h.Ready, h.Closed, h.Stalled.String(), !h.BackendClosed, backendError})
+		}
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/healthz", check(false))
+	mux.Handle("/readyz", check(true))
+	return mux
+}
@@ -0,0 +1,78 @@
+package pkgwatcher
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// How entries whose names start with a dot are handled.
+type HiddenPolicy int
+
+const (
+	// Skip hidden directories but deliver events for hidden files.
+	SkipHiddenDirs HiddenPolicy = iota
+	// Skip hidden directories and drop events for hidden files.
+	SkipHiddenAll
+	// Treat hidden entries like any other.
+	SkipHiddenNone
+)
+
+func (p HiddenPolicy) String() string {
+	switch p {
+	case SkipHiddenDirs:
+		return "SkipHiddenDirs"
+	case SkipHiddenAll:
+		return "SkipHiddenAll"
+	case SkipHiddenNone:
+		return "SkipHiddenNone"
+	}
+	return fmt.Sprintf("HiddenPolicy(%d)", int(p))
+}
+
+// Set how hidden directories and files are handled, SkipHiddenDirs by
+// default. Names matching one of the exceptions, where * matches
+// anything, are never treated as hidden, for example ".env" or
+// ".golangci.*". Applies to directories walked afterwards.
+func (w *Watcher) SetHiddenPolicy(p HiddenPolicy, exceptions ...string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.hidden = p
+	w.hiddenExceptions = exceptions
+}
+
+// Set how hidden directories and files are handled.
+func WithHiddenPolicy(p HiddenPolicy, exceptions ...string) Option {
+	return func(w *Watcher) {
+		w.hidden = p
+		w.hiddenExceptions = exceptions
+	}
+}
+
+// Check if a name is hidden and skipped for the given kind of entry.
+func (w *Watcher) skipHidden(name string, dir bool) bool {
+	if !strings.HasPrefix(name, ".") || name == "." || name == ".." {
+		return false
+	}
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	switch w.hidden {
+	case SkipHiddenNone:
+		return false
+	case SkipHiddenDirs:
+		if !dir {
+			return false
+		}
+	}
+	for _, pattern := range w.hiddenExceptions {
+		if globMatch(pattern, name) {
+			return false
+		}
+	}
+	return true
+}
+
+// Check if an event is for a hidden file that is skipped.
+func (w *Watcher) hiddenFile(ev *Event) bool {
+	return ev.FileEvent != nil && w.skipHidden(filepath.Base(ev.Name), false)
+}
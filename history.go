@@ -0,0 +1,111 @@
+package pkgwatcher
+
+import (
+	"sync"
+	"time"
+)
+
+// Default number of events kept in the history.
+const DefaultHistorySize = 256
+
+// A bounded ring buffer of the events recently sent on the Event
+// channel.
+type history struct {
+	mu     sync.Mutex
+	events []*Event
+	next   int
+	full   bool
+}
+
+// Resize the buffer, keeping the most recent events that still fit.
+func (h *history) resize(size int) {
+	if size > MaxHistorySize {
+		size = MaxHistorySize
+	}
+	if size < 0 {
+		size = 0
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if size == len(h.events) {
+		return
+	}
+	old := h.ordered()
+	if len(old) > size {
+		old = old[len(old)-size:]
+	}
+	h.events = make([]*Event, size)
+	h.next = copy(h.events, old)
+	h.full = h.next == size
+	if h.full {
+		h.next = 0
+	}
+}
+
+// Record an event, evicting the oldest one if the buffer is full.
+func (h *history) add(ev *Event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if len(h.events) == 0 {
+		return
+	}
+	h.events[h.next] = ev
+	h.next = (h.next + 1) % len(h.events)
+	if h.next == 0 {
+		h.full = true
+	}
+}
+
+// Events from oldest to newest. Must be called with the lock held.
+func (h *history) ordered() []*Event {
+	if !h.full {
+		return append([]*Event(nil), h.events[:h.next]...)
+	}
+	return append(append([]*Event(nil), h.events[h.next:]...), h.events[:h.next]...)
+}
+
+// The last n events from oldest to newest.
+func (h *history) last(n int) []*Event {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	all := h.ordered()
+	if n < 0 {
+		n = 0
+	}
+	if n < len(all) {
+		all = all[len(all)-n:]
+	}
+	return all
+}
+
+// Events with a time after the given one in delivery order. Debouncing
+// can deliver an event after a newer one, so all events are checked.
+func (h *history) since(t time.Time) []*Event {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	var events []*Event
+	for _, ev := range h.ordered() {
+		if ev.Time.After(t) {
+			events = append(events, ev)
+		}
+	}
+	return events
+}
+
+// Set the number of events kept in the history, up to MaxHistorySize. A
+// size of 0 or less disables the history.
+func (w *Watcher) SetHistorySize(size int) {
+	w.history.resize(size)
+}
+
+// The last n events delivered on the Event channel, oldest first.
+// Events superseded while debouncing or dropped are not included.
+func (w *Watcher) Recent(n int) []*Event {
+	return w.history.last(n)
+}
+
+// Events delivered on the Event channel whose Time is after the given
+// time, in delivery order.
+func (w *Watcher) RecentSince(t time.Time) []*Event {
+	return w.history.since(t)
+}
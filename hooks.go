@@ -0,0 +1,118 @@
+package pkgwatcher
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"time"
+)
+
+// How long a hook may run before it is killed.
+const DefaultHookTimeout = 10 * time.Second
+
+// The JSON reply of a hook. An empty reply leaves the event unchanged.
+type hookDirective struct {
+	Suppress    bool              `json:"suppress"`
+	Annotations map[string]string `json:"annotations"`
+}
+
+// Register an external executable to be run for every event. The event
+// is written to its stdin as a SerializedEvent. It may reply on stdout
+// with a JSON object containing "suppress": true to drop the event, and
+// "annotations" with string values to add to Event.Annotations. Hooks
+// run in the order they were added, and a failing hook leaves the event
+// unchanged, as does one killed after the timeout set with SetHookTimeout
+// which is also delivered as a HookTimedOut Failure.
+func (w *Watcher) AddHook(name string, args ...string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.hooks = append(w.hooks, append([]string{name}, args...))
+}
+
+// Set how long a hook may run for an event before it is killed, leaving
+// the event unchanged. Hooks run before events are delivered, so a hook
+// that hangs holds up delivery until then. A timeout of 0 means no
+// timeout. Hooks still running are killed on Close.
+func (w *Watcher) SetHookTimeout(d time.Duration) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.hookTimeout = d
+}
+
+// Kill hooks running for longer than the given timeout.
+func WithHookTimeout(d time.Duration) Option {
+	return func(w *Watcher) {
+		w.hookTimeout = d
+	}
+}
+
+// Run the hooks for an event, returning false if it was suppressed.
+func (w *Watcher) runHooks(ev *Event) bool {
+	w.mu.RLock()
+	hooks := w.hooks
+	timeout := w.hookTimeout
+	w.mu.RUnlock()
+	if len(hooks) == 0 || ev.FileEvent == nil {
+		return true
+	}
+	in, err := json.Marshal(Serialize(ev))
+	if err != nil {
+		return true
+	}
+	for _, hook := range hooks {
+		out, timedOut, err := w.runHook(hook, in, timeout)
+		if w.closed() {
+			return true
+		}
+		if err != nil {
+			w.reportError(err)
+			if timedOut {
+				w.fail(HookTimedOut, ev.Package, err)
+			}
+			continue
+		}
+		if len(bytes.TrimSpace(out)) == 0 {
+			continue
+		}
+		var d hookDirective
+		if err := json.Unmarshal(out, &d); err != nil {
+			w.reportError(fmt.Errorf("Hook %s replied with invalid JSON: %s", hook[0], err))
+			continue
+		}
+		if d.Suppress {
+			return false
+		}
+		for k, v := range d.Annotations {
+			if ev.Annotations == nil {
+				ev.Annotations = make(map[string]string)
+			}
+			ev.Annotations[k] = v
+		}
+	}
+	return true
+}
+
+// Run a hook with the given input, killing it after the timeout or on
+// Close. Reports if the hook timed out.
+func (w *Watcher) runHook(hook []string, in []byte, timeout time.Duration) ([]byte, bool, error) {
+	ctx := w.hookContext
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	cmd := exec.CommandContext(ctx, hook[0], hook[1:]...)
+	cmd.Stdin = bytes.NewReader(in)
+	// do not wait on children holding on to stdout once killed
+	cmd.WaitDelay = time.Second
+	out, err := cmd.Output()
+	if err != nil && ctx.Err() == context.DeadlineExceeded {
+		return nil, true, fmt.Errorf("Hook %s timed out after %s", hook[0], timeout)
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("Hook %s failed: %s", hook[0], err)
+	}
+	return out, false, nil
+}
@@ -0,0 +1,87 @@
+package pkgwatcher
+
+import (
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Watch package p with a hook running the given shell script.
+func hookWatcher(t *testing.T, script string, options ...Option) (*Watcher, string, chan error) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("no shell to run hooks with")
+	}
+	gopath := tempGopath(t, map[string]string{"p/p.go": "package p\n"})
+	dir := filepath.Join(gopath, "src", "p")
+	w, _ := testWatcher(t, gopath, dir, options...)
+	errs := make(chan error, 10)
+	w.SetErrorHandler(func(err error) { errs <- err })
+	w.WatchImportPath("p", false)
+	w.AddHook("sh", "-c", script)
+	return w, filepath.Join(dir, "p.go"), errs
+}
+
+func TestHookDirectives(t *testing.T) {
+	w, name, _ := hookWatcher(t, `
+		case "$(cat)" in
+		*'"op":"delete"'*) echo '{"suppress": true}' ;;
+		*) echo '{"annotations": {"by": "hook"}}' ;;
+		esac`)
+	injectFile(w, name, OpDelete)
+	injectFile(w, name, OpModify)
+	ev := nextEvent(t, w)
+	if ev.Op != OpModify || ev.Annotations["by"] != "hook" {
+		t.Fatalf("got %s with annotations %v, want the annotated write", ev.Op, ev.Annotations)
+	}
+}
+
+func TestHookTimeout(t *testing.T) {
+	w, name, errs := hookWatcher(t, "sleep 60",
+		WithHookTimeout(100*time.Millisecond), WithFailures(1))
+	start := time.Now()
+	injectFile(w, name, OpModify)
+	if ev := nextEvent(t, w); ev.Name != name {
+		t.Fatalf("got event for %s, want %s", ev.Name, name)
+	}
+	if d := time.Since(start); d > 10*time.Second {
+		t.Errorf("event delivered after %s", d)
+	}
+	select {
+	case err := <-errs:
+		if !strings.Contains(err.Error(), "timed out") {
+			t.Errorf("got error %q, want a timeout", err)
+		}
+	default:
+		t.Error("timeout not reported")
+	}
+	select {
+	case f := <-w.Failure:
+		if f.Kind != HookTimedOut || f.Package == nil || f.Package.ImportPath != "p" {
+			t.Errorf("got %s failure, want HookTimedOut for p", f.Kind)
+		}
+	default:
+		t.Error("timeout not delivered as a Failure")
+	}
+}
+
+func TestCloseKillsHooks(t *testing.T) {
+	w, name, errs := hookWatcher(t, "sleep 60", WithHookTimeout(0))
+	injectFile(w, name, OpModify)
+	// the hook is running once the event is past the filters
+	time.Sleep(100 * time.Millisecond)
+	closed := make(chan bool)
+	go func() {
+		w.Close()
+		close(closed)
+	}()
+	select {
+	case <-closed:
+	case <-time.After(10 * time.Second):
+		t.Fatal("Close waited for the hook")
+	}
+	if len(errs) > 0 {
+		t.Errorf("got error %q for the killed hook", <-errs)
+	}
+}
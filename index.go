@@ -0,0 +1,71 @@
+package pkgwatcher
+
+import (
+	"go/build"
+	"hash/fnv"
+	"sync"
+)
+
+// Number of shards in a dirIndex.
+const dirShards = 32
+
+// A sharded index of packages by directory, allowing the event path to
+// look up packages without contending with resolution or other readers
+// on a single lock. Directories usually hold one package, but may hold
+// more, the first being the one in DirPackages.
+type dirIndex struct {
+	shards [dirShards]struct {
+		sync.RWMutex
+		m map[string][]*build.Package
+	}
+}
+
+// The shard responsible for a directory.
+func (x *dirIndex) shard(dir string) int {
+	h := fnv.New32a()
+	h.Write([]byte(dir))
+	return int(h.Sum32() % dirShards)
+}
+
+// Get the package in a directory.
+func (x *dirIndex) get(dir string) *build.Package {
+	s := &x.shards[x.shard(dir)]
+	s.RLock()
+	defer s.RUnlock()
+	if pkgs := s.m[dir]; len(pkgs) > 0 {
+		return pkgs[0]
+	}
+	return nil
+}
+
+// Get all the packages in a directory.
+func (x *dirIndex) all(dir string) []*build.Package {
+	s := &x.shards[x.shard(dir)]
+	s.RLock()
+	defer s.RUnlock()
+	return s.m[dir]
+}
+
+// Set the package in a directory.
+func (x *dirIndex) set(dir string, pkg *build.Package) {
+	x.setAll(dir, []*build.Package{pkg})
+}
+
+// Set all the packages in a directory.
+func (x *dirIndex) setAll(dir string, pkgs []*build.Package) {
+	s := &x.shards[x.shard(dir)]
+	s.Lock()
+	defer s.Unlock()
+	if s.m == nil {
+		s.m = make(map[string][]*build.Package)
+	}
+	s.m[dir] = pkgs
+}
+
+// Remove the package in a directory.
+func (x *dirIndex) remove(dir string) {
+	s := &x.shards[x.shard(dir)]
+	s.Lock()
+	defer s.Unlock()
+	delete(s.m, dir)
+}
@@ -0,0 +1,120 @@
+package pkgwatcher
+
+import (
+	"fmt"
+	"github.com/howeyc/fsnotify"
+	"go/build"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+// Number of packages in the benchmark GOPATH.
+const benchPackages = 2000
+
+// Start a Watcher over a GOPATH of n packages imported by a root package,
+// returning it along with the name of a file in each package.
+func manyPackages(tb testing.TB, n int) (*Watcher, []string) {
+	files := make(map[string]string)
+	root := "package root\n\nimport (\n"
+	for i := 0; i < n; i++ {
+		files[fmt.Sprintf("p%d/p.go", i)] = fmt.Sprintf("package p%d\n", i)
+		root += fmt.Sprintf("\t_ \"p%d\"\n", i)
+	}
+	files["root/root.go"] = root + ")\n"
+	gopath := tempGopath(tb, files)
+	w, _ := testWatcher(tb, gopath, filepath.Join(gopath, "src", "root"))
+	w.WatchImportPath("root", false)
+	names := make([]string, n)
+	for i := range names {
+		names[i] = filepath.Join(gopath, "src", fmt.Sprintf("p%d", i), "p.go")
+	}
+	return w, names
+}
+
+func TestDirIndexConcurrentAccess(t *testing.T) {
+	var x dirIndex
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 500; i++ {
+				dir := fmt.Sprintf("/src/p%d/%d", g, i)
+				x.set(dir, &build.Package{Dir: dir})
+				if pkg := x.get(dir); pkg == nil || pkg.Dir != dir {
+					t.Errorf("get(%s) = %v", dir, pkg)
+					return
+				}
+				if i%2 == 0 {
+					x.remove(dir)
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+	for g := 0; g < 8; g++ {
+		if x.get(fmt.Sprintf("/src/p%d/0", g)) != nil {
+			t.Errorf("removed directory still indexed")
+		}
+		if x.get(fmt.Sprintf("/src/p%d/1", g)) == nil {
+			t.Errorf("directory missing from index")
+		}
+	}
+}
+
+// Look up packages for files from many goroutines, as the event path
+// does during an event storm.
+func BenchmarkFindPackageParallel(b *testing.B) {
+	w, names := manyPackages(b, benchPackages)
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			if w.findPackage(names[i%len(names)]) == nil {
+				b.Error("package not found")
+			}
+			i++
+		}
+	})
+}
+
+// Deliver events while other goroutines query the watch set.
+func BenchmarkEventPathWithConcurrentQueries(b *testing.B) {
+	w, names := manyPackages(b, benchPackages)
+	stop := make(chan bool)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-w.Event:
+			case <-stop:
+				return
+			}
+		}
+	}()
+	for q := 0; q < 4; q++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				w.PackageList()
+				w.dirPackage(filepath.Dir(names[0]))
+			}
+		}()
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		w.deliver(&Event{FileEvent: &fsnotify.FileEvent{Name: names[i%len(names)]}})
+	}
+	b.StopTimer()
+	close(stop)
+	wg.Wait()
+}
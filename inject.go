@@ -0,0 +1,47 @@
+package pkgwatcher
+
+import (
+	"sync"
+)
+
+// Injected events waiting to be delivered.
+type injector struct {
+	mu     sync.Mutex
+	queue  []*Event
+	notify chan bool // signaled when the queue becomes non empty
+}
+
+// Inject a synthetic event into the delivery pipeline as if it had
+// come from the file system. If the Package is not set, it will be
+// filled in based on the file name. Events injected after the Watcher
+// is closed are dropped.
+//
+// Inject never waits for the event to be delivered, so it may be called
+// from the goroutine reading the Event channel, for example to force a
+// rebuild. Injected events are queued without bound until delivered.
+func (w *Watcher) Inject(ev *Event) {
+	if w.closed() {
+		return
+	}
+	in := &w.injector
+	in.mu.Lock()
+	in.queue = append(in.queue, ev)
+	in.mu.Unlock()
+	select {
+	case in.notify <- true:
+	default:
+	}
+}
+
+// Take the next injected event, or nil if there is none.
+func (in *injector) next() *Event {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	if len(in.queue) == 0 {
+		return nil
+	}
+	ev := in.queue[0]
+	in.queue[0] = nil
+	in.queue = in.queue[1:]
+	return ev
+}
@@ -0,0 +1,50 @@
+package pkgwatcher
+
+import (
+	"github.com/howeyc/fsnotify"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestInjectEventsWithoutFile(t *testing.T) {
+	cases := []struct {
+		name     string
+		debounce time.Duration
+		fast     []string
+	}{
+		{"default", 0, nil},
+		{"debounced", time.Hour, nil},
+		{"low latency", time.Hour, []string{"p"}},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			gopath := tempGopath(t, map[string]string{"p/p.go": "package p\n"})
+			dir := filepath.Join(gopath, "src", "p")
+			w, b := testWatcher(t, gopath, dir)
+			w.debouncer.delay = c.debounce
+			w.SetLowLatency(c.fast...)
+			w.WatchImportPath("p", false)
+			pkg := w.dirPackage(dir)
+
+			w.Inject(&Event{Package: pkg})
+			if ev := nextEvent(t, w); ev.Package != pkg || ev.FileEvent != nil {
+				t.Errorf("got %+v, want the package only event", ev)
+			}
+			w.Inject(&Event{})
+			if ev := nextEvent(t, w); ev.Package != nil || ev.FileEvent != nil {
+				t.Errorf("got %+v, want the empty event", ev)
+			}
+
+			// the pipeline still delivers file events
+			if c.debounce > 0 {
+				w.SetLowLatency("p")
+			}
+			name := filepath.Join(dir, "p.go")
+			b.events <- &fsnotify.FileEvent{Name: name}
+			if ev := nextEvent(t, w); ev.Name != name {
+				t.Errorf("got event for %q, want %q", ev.Name, name)
+			}
+		})
+	}
+}
@@ -0,0 +1,46 @@
+//go:build linux
+// +build linux
+
+package pkgwatcher
+
+import (
+	"bufio"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// The number of inotify watches held by this process and the per user
+// limit, or -1 if unknown.
+func kernelWatches() (used, max int) {
+	max = -1
+	if b, err := ioutil.ReadFile("/proc/sys/fs/inotify/max_user_watches"); err == nil {
+		if n, err := strconv.Atoi(strings.TrimSpace(string(b))); err == nil {
+			max = n
+		}
+	}
+	fds, err := ioutil.ReadDir("/proc/self/fd")
+	if err != nil {
+		return -1, max
+	}
+	for _, fd := range fds {
+		link, err := os.Readlink(filepath.Join("/proc/self/fd", fd.Name()))
+		if err != nil || link != "anon_inode:inotify" {
+			continue
+		}
+		f, err := os.Open(filepath.Join("/proc/self/fdinfo", fd.Name()))
+		if err != nil {
+			continue
+		}
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			if strings.HasPrefix(scanner.Text(), "inotify wd:") {
+				used++
+			}
+		}
+		f.Close()
+	}
+	return used, max
+}
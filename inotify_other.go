@@ -0,0 +1,9 @@
+//go:build !linux
+// +build !linux
+
+package pkgwatcher
+
+// Kernel watch counts are only known on linux.
+func kernelWatches() (used, max int) {
+	return -1, -1
+}
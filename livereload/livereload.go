@@ -0,0 +1,205 @@
+// Package livereload provides an http middleware that automatically
+// reloads browsers when watched packages change.
+package livereload
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"github.com/daaku/go.pkgwatcher"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// The path the event stream is served on by the middleware.
+const Path = "/_pkgwatcher/livereload"
+
+// The script injected into HTML responses.
+const Script = `<script>new EventSource("` + Path +
+	`").addEventListener("reload", function() { location.reload() })</script>`
+
+// A Server broadcasts reload notifications to connected browsers using
+// server-sent events.
+type Server struct {
+	mu      sync.Mutex
+	clients map[chan bool]bool
+}
+
+// Create a new Server.
+func New() *Server {
+	return &Server{clients: make(map[chan bool]bool)}
+}
+
+// Tell all connected browsers to reload.
+func (s *Server) Reload() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for c := range s.clients {
+		select {
+		case c <- true:
+		default: // a reload is already pending
+		}
+	}
+}
+
+// Reload connected browsers for each event received until the channel
+// is closed.
+func (s *Server) Watch(events <-chan *pkgwatcher.Event) {
+	s.WatchFunc(events, nil)
+}
+
+// Reload connected browsers for each event received that accept returns
+// true for, until the channel is closed. A nil accept accepts all
+// events.
+func (s *Server) WatchFunc(events <-chan *pkgwatcher.Event, accept func(*pkgwatcher.Event) bool) {
+	for ev := range events {
+		if accept == nil || accept(ev) {
+			s.Reload()
+		}
+	}
+}
+
+// Accept events in the asset directories of packages, such as
+// templates, and events for the packages with the given import paths,
+// typically the main package.
+func AssetsOrPackages(importPaths ...string) func(*pkgwatcher.Event) bool {
+	return func(ev *pkgwatcher.Event) bool {
+		if ev.AssetDir != "" {
+			return true
+		}
+		if ev.Package == nil {
+			return false
+		}
+		for _, path := range importPaths {
+			if ev.Package.ImportPath == path {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// Serve the event stream.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	c := make(chan bool, 1)
+	s.mu.Lock()
+	s.clients[c] = true
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.clients, c)
+		s.mu.Unlock()
+	}()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+	for {
+		select {
+		case <-c:
+			fmt.Fprint(w, "event: reload\ndata: \n\n")
+			flusher.Flush()
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// Wrap a handler to serve the event stream at Path and inject the
+// reload Script into HTML responses.
+func (s *Server) Handler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == Path {
+			s.ServeHTTP(w, r)
+			return
+		}
+		rec := &recorder{ResponseWriter: w, status: http.StatusOK}
+		h.ServeHTTP(rec, r)
+		rec.finish()
+	})
+}
+
+// Buffers HTML responses so the script can be injected. Other responses
+// are passed through, including flushes and hijacking.
+type recorder struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+	html        bool
+	buf         bytes.Buffer
+}
+
+func (r *recorder) WriteHeader(status int) {
+	if r.wroteHeader {
+		return
+	}
+	r.wroteHeader = true
+	r.status = status
+	ct := r.Header().Get("Content-Type")
+	r.html = strings.HasPrefix(ct, "text/html") &&
+		r.Header().Get("Content-Encoding") == ""
+	if !r.html {
+		r.ResponseWriter.WriteHeader(status)
+	}
+}
+
+func (r *recorder) Write(b []byte) (int, error) {
+	if !r.wroteHeader {
+		if r.Header().Get("Content-Type") == "" {
+			r.Header().Set("Content-Type", http.DetectContentType(b))
+		}
+		r.WriteHeader(http.StatusOK)
+	}
+	if r.html {
+		return r.buf.Write(b)
+	}
+	return r.ResponseWriter.Write(b)
+}
+
+// Flush a response that is not buffered.
+func (r *recorder) Flush() {
+	if !r.wroteHeader {
+		r.WriteHeader(http.StatusOK)
+	}
+	if f, ok := r.ResponseWriter.(http.Flusher); ok && !r.html {
+		f.Flush()
+	}
+}
+
+// Hijack the connection, as done for websockets.
+func (r *recorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := r.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("Hijacking is not supported by the ResponseWriter")
+	}
+	return h.Hijack()
+}
+
+// The wrapped ResponseWriter, for http.ResponseController.
+func (r *recorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+// Write out the buffered response, if any, including the script.
+func (r *recorder) finish() {
+	if !r.html {
+		return
+	}
+	body := r.buf.Bytes()
+	if i := bytes.LastIndex(bytes.ToLower(body), []byte("</body>")); i >= 0 {
+		body = append(body[:i:i], append([]byte(Script), body[i:]...)...)
+	} else {
+		body = append(body, Script...)
+	}
+	r.Header().Del("Content-Length")
+	r.ResponseWriter.WriteHeader(r.status)
+	r.ResponseWriter.Write(body)
+}
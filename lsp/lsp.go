@@ -0,0 +1,138 @@
+// Package lsp writes pkgwatcher events as Language Server Protocol
+// workspace/didChangeWatchedFiles notifications, allowing editor plugins
+// to use pkgwatcher as a dependency aware external file watcher.
+package lsp
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/daaku/go.pkgwatcher"
+	"io"
+	"net/url"
+	"path/filepath"
+	"sync"
+)
+
+// The LSP FileChangeType values.
+const (
+	Created = 1
+	Changed = 2
+	Deleted = 3
+)
+
+// The LSP WatchKind values.
+const (
+	WatchCreate = 1
+	WatchChange = 2
+	WatchDelete = 4
+)
+
+// A watcher registration for workspace/didChangeWatchedFiles.
+type FileSystemWatcher struct {
+	GlobPattern string `json:"globPattern"`
+	Kind        int    `json:"kind,omitempty"`
+}
+
+// The registrations matching the current watch set of w, for clients
+// that need to register the same patterns with the editor.
+func Watchers(w *pkgwatcher.Watcher) []FileSystemWatcher {
+	var watchers []FileSystemWatcher
+	for _, glob := range w.LSPGlobs() {
+		watchers = append(watchers, FileSystemWatcher{
+			GlobPattern: glob,
+			Kind:        WatchCreate | WatchChange | WatchDelete,
+		})
+	}
+	return watchers
+}
+
+// A single file change in a notification. ImportPath is an extension to
+// the protocol identifying the package containing the file.
+type FileEvent struct {
+	URI        string `json:"uri"`
+	Type       int    `json:"type"`
+	ImportPath string `json:"importPath,omitempty"`
+}
+
+type params struct {
+	Changes []FileEvent `json:"changes"`
+}
+
+type notification struct {
+	JSONRPC string `json:"jsonrpc"`
+	Method  string `json:"method"`
+	Params  params `json:"params"`
+}
+
+// Writes notifications using the LSP base protocol framing.
+type Writer struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// Create a new Writer.
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{w: w}
+}
+
+// Write a single notification for the given events.
+func (w *Writer) Write(events ...*pkgwatcher.Event) error {
+	n := notification{JSONRPC: "2.0", Method: "workspace/didChangeWatchedFiles"}
+	for _, ev := range events {
+		if ev.FileEvent == nil {
+			continue
+		}
+		fe := FileEvent{URI: URI(ev.Name), Type: changeType(ev)}
+		if ev.Package != nil {
+			fe.ImportPath = ev.Package.ImportPath
+		}
+		n.Params.Changes = append(n.Params.Changes, fe)
+	}
+	if len(n.Params.Changes) == 0 {
+		return nil
+	}
+	body, err := json.Marshal(n)
+	if err != nil {
+		return err
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if _, err := fmt.Fprintf(w.w, "Content-Length: %d\r\n\r\n", len(body)); err != nil {
+		return err
+	}
+	_, err = w.w.Write(body)
+	return err
+}
+
+// Write a notification for each event received until the channel is
+// closed or a write fails.
+func (w *Writer) WriteAll(events <-chan *pkgwatcher.Event) error {
+	for ev := range events {
+		if err := w.Write(ev); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// The file URI for a path.
+func URI(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	path = filepath.ToSlash(path)
+	if len(path) > 0 && path[0] != '/' {
+		path = "/" + path // windows drive letters
+	}
+	return (&url.URL{Scheme: "file", Path: path}).String()
+}
+
+func changeType(ev *pkgwatcher.Event) int {
+	switch {
+	case ev.IsCreate():
+		return Created
+	case ev.IsDelete(), ev.IsRename():
+		return Deleted
+	}
+	return Changed
+}
@@ -0,0 +1,63 @@
+package pkgwatcher
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// The import path of the package made of a single file watched with
+// WatchMainFile, as used by the go command for files given as arguments.
+const MainImportPath = "command-line-arguments"
+
+// Watch a single Go file that is not part of an importable package, such
+// as a script run with go run, along with the packages it imports. Only
+// the file itself is watched in its directory, and events for it are
+// attributed to a package with the MainImportPath import path.
+func (w *Watcher) WatchMainFile(path string) error {
+	if err := w.resolveMainFile(w.absPath(path)); err != nil {
+		return err
+	}
+	w.watchPackages()
+	return nil
+}
+
+// Resolve a main file and the packages it imports.
+func (w *Watcher) resolveMainFile(file string) error {
+	dir := filepath.Dir(file)
+	w.mu.RLock()
+	ctxt := w.context
+	w.mu.RUnlock()
+	ctxt.ReadDir = func(path string) ([]os.FileInfo, error) {
+		if path != dir {
+			return ioutil.ReadDir(path)
+		}
+		info, err := os.Stat(file)
+		if err != nil {
+			return nil, err
+		}
+		return []os.FileInfo{info}, nil
+	}
+	pkg, err := ctxt.ImportDir(dir, 0)
+	if err != nil {
+		return fmt.Errorf("Failed to import %s with error %s", file, err)
+	}
+	pkg.ImportPath = MainImportPath
+
+	w.mu.Lock()
+	w.Packages[pkg.ImportPath] = pkg
+	w.depth[pkg.ImportPath] = 0
+	w.mainFile = file
+	w.mu.Unlock()
+	// indexed by the file so only it is attributed to the package
+	w.dirs.set(file, pkg)
+
+	opts := RootOptions{Dir: dir}
+	for _, path := range pkg.Imports {
+		if w.follows(path) {
+			w.resolveWith(w.resolverFor(opts), nil, path, 1, false, opts)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,131 @@
+package pkgwatcher
+
+import (
+	"go/build"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// Upper bound on the number of events kept in the history.
+const MaxHistorySize = 1 << 14
+
+// Number of strings kept by the interner before it starts over.
+const maxInterned = 1 << 12
+
+// Rough per entry overhead of a map entry, in bytes.
+const mapEntryOverhead = 48
+
+// Deduplicates the file names of events, so the history does not keep
+// a copy of the same path for every save of a file.
+type interner struct {
+	mu      sync.Mutex
+	strings map[string]string
+}
+
+// Return the canonical copy of s.
+func (in *interner) intern(s string) string {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	if c, ok := in.strings[s]; ok {
+		return c
+	}
+	if in.strings == nil || len(in.strings) >= maxInterned {
+		in.strings = make(map[string]string)
+	}
+	in.strings[s] = s
+	return s
+}
+
+// Forget the analysis and parse results for a removed package, and
+// rebuild the package maps once enough entries were removed, since maps
+// never shrink on their own.
+func (w *Watcher) forgetPackage(pkg *build.Package) {
+	prefix := pkg.Dir + string(filepath.Separator)
+	a := &w.analysis
+	a.mu.Lock()
+	delete(a.analyzed, pkg.ImportPath)
+	delete(a.uses, pkg.ImportPath)
+	for file := range a.decls {
+		if strings.HasPrefix(file, prefix) {
+			delete(a.decls, file)
+		}
+	}
+	a.mu.Unlock()
+	a.cache.forget(prefix)
+	w.diagnoser.mu.Lock()
+	delete(w.diagnoser.apis, pkg.ImportPath)
+	w.diagnoser.mu.Unlock()
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for _, t := range w.targets {
+		delete(t.packages, pkg.ImportPath)
+	}
+	w.removals++
+	if w.removals < 64 || w.removals < len(w.Packages) {
+		return
+	}
+	w.removals = 0
+	w.Packages = copyPackages(w.Packages)
+	w.DirPackages = copyPackages(w.DirPackages)
+	w.watchedDirectories = copyBools(w.watchedDirectories)
+	w.watchedFiles = copyBools(w.watchedFiles)
+	depth := make(map[string]int, len(w.depth))
+	for k, v := range w.depth {
+		depth[k] = v
+	}
+	w.depth = depth
+	expanded := make(map[string]expansion, len(w.expanded))
+	for k, v := range w.expanded {
+		expanded[k] = v
+	}
+	w.expanded = expanded
+}
+
+func copyPackages(m map[string]*build.Package) map[string]*build.Package {
+	c := make(map[string]*build.Package, len(m))
+	for k, v := range m {
+		c[k] = v
+	}
+	return c
+}
+
+func copyBools(m map[string]bool) map[string]bool {
+	c := make(map[string]bool, len(m))
+	for k, v := range m {
+		c[k] = v
+	}
+	return c
+}
+
+// A rough estimate of the memory held by the Watcher in bytes, counting
+// the package and directory maps, cached sources and the history.
+func (w *Watcher) memoryEstimate() int {
+	n := 0
+	w.mu.RLock()
+	for path, pkg := range w.Packages {
+		n += 2*mapEntryOverhead + 2*len(path) + len(pkg.Dir) + len(pkg.Name)
+		for _, name := range sourceFiles(pkg) {
+			n += len(name)
+		}
+		for _, path := range pkg.Imports {
+			n += len(path)
+		}
+	}
+	for dir := range w.watchedDirectories {
+		n += mapEntryOverhead + len(dir)
+	}
+	for file := range w.watchedFiles {
+		n += mapEntryOverhead + len(file)
+	}
+	w.mu.RUnlock()
+	n += w.analysis.cache.size()
+	for _, ev := range w.history.last(MaxHistorySize) {
+		n += mapEntryOverhead
+		if ev.FileEvent != nil {
+			n += len(ev.Name)
+		}
+	}
+	return n
+}
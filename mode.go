@@ -0,0 +1,89 @@
+package pkgwatcher
+
+import (
+	"go/build"
+	"os"
+	"path/filepath"
+)
+
+// How import paths are resolved.
+type Mode int
+
+const (
+	// Detect the mode from GO111MODULE and the presence of go.mod.
+	AutoMode Mode = iota
+	// Resolve packages in GOPATH and GOROOT.
+	GOPATHMode
+	// Resolve packages using the go command in module mode.
+	ModuleMode
+)
+
+func (m Mode) String() string {
+	switch m {
+	case AutoMode:
+		return "auto"
+	case GOPATHMode:
+		return "gopath"
+	case ModuleMode:
+		return "module"
+	}
+	return "unknown"
+}
+
+// Detect the mode the go command would use in the given directory based
+// on GO111MODULE and whether the directory is inside a module.
+func DetectMode(dir string) Mode {
+	switch os.Getenv("GO111MODULE") {
+	case "off":
+		return GOPATHMode
+	case "on":
+		return ModuleMode
+	}
+	for d := dir; ; d = filepath.Dir(d) {
+		if _, err := os.Stat(filepath.Join(d, "go.mod")); err == nil {
+			return ModuleMode
+		}
+		if filepath.Dir(d) == d {
+			return GOPATHMode
+		}
+	}
+}
+
+// Set the resolution mode, overriding detection. Applies to packages
+// resolved afterwards, unless a Resolver was set with WithResolver.
+func (w *Watcher) SetMode(m Mode) {
+	w.mu.Lock()
+	w.mode = m
+	w.mu.Unlock()
+	w.analysis.cache.invalidateAll()
+}
+
+// Use the given resolution mode instead of detecting it.
+func WithMode(m Mode) Option {
+	return func(w *Watcher) {
+		w.mode = m
+	}
+}
+
+// The mode in effect. Must be called with the lock held.
+func (w *Watcher) effectiveMode() Mode {
+	if w.mode != AutoMode {
+		return w.mode
+	}
+	return DetectMode(w.workingDirectory)
+}
+
+// Resolves packages through the go command, which go/build only uses
+// when binary packages are not allowed. The go command is run in the
+// source directory so it finds the right module.
+type moduleResolver struct {
+	ctxt *build.Context
+}
+
+func (r moduleResolver) Import(path, srcDir string, mode build.ImportMode) (*build.Package, error) {
+	ctxt := *r.ctxt
+	if ctxt.Dir == "" && filepath.IsAbs(srcDir) {
+		ctxt.Dir = srcDir
+	}
+	return ctxt.Import(path, srcDir, mode&^build.AllowBinary)
+}
@@ -0,0 +1,68 @@
+package pkgwatcher
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Detect the -mod flag the go command uses in a directory inside a
+// module: the value from GOFLAGS if set, otherwise vendor if the module
+// has a vendor/modules.txt and declares go 1.14 or later, otherwise
+// readonly. Dependencies resolve to the vendor directory in vendor mode
+// and to the module cache otherwise. Returns an empty string outside a
+// module.
+func DetectModFlag(dir string) string {
+	root := ""
+	for d := dir; ; d = filepath.Dir(d) {
+		if _, err := os.Stat(filepath.Join(d, "go.mod")); err == nil {
+			root = d
+			break
+		}
+		if filepath.Dir(d) == d {
+			return ""
+		}
+	}
+	if flag := goflagsMod(os.Getenv("GOFLAGS")); flag != "" {
+		return flag
+	}
+	gomod, err := parseGoMod(filepath.Join(root, "go.mod"))
+	if err != nil {
+		return "readonly"
+	}
+	if _, err := os.Stat(filepath.Join(root, "vendor", "modules.txt")); err == nil &&
+		goVersionAtLeast(gomod.goVersion, 1, 14) {
+		return "vendor"
+	}
+	return "readonly"
+}
+
+// Extract the -mod flag from a GOFLAGS value.
+func goflagsMod(goflags string) string {
+	var flag string
+	for _, f := range strings.Fields(goflags) {
+		f = strings.TrimPrefix(strings.TrimPrefix(f, "-"), "-")
+		if strings.HasPrefix(f, "mod=") {
+			flag = strings.TrimPrefix(f, "mod=")
+		}
+	}
+	return flag
+}
+
+// Check if a go directive version like 1.21 or 1.21.3 is at least the
+// given major and minor version.
+func goVersionAtLeast(version string, major, minor int) bool {
+	parts := strings.SplitN(version, ".", 3)
+	if len(parts) < 2 {
+		return false
+	}
+	ma, err1 := strconv.Atoi(parts[0])
+	mi, err2 := strconv.Atoi(strings.TrimRightFunc(parts[1], func(r rune) bool {
+		return r < '0' || r > '9'
+	}))
+	if err1 != nil || err2 != nil {
+		return false
+	}
+	return ma > major || (ma == major && mi >= minor)
+}
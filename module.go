@@ -0,0 +1,101 @@
+package pkgwatcher
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Find the module containing a resolved import path. Returns nil if the
+// package is unknown or not part of a module, as in GOPATH mode.
+func (w *Watcher) ModuleFor(importPath string) *Module {
+	w.mu.RLock()
+	pkg := w.Packages[importPath]
+	w.mu.RUnlock()
+	if pkg == nil {
+		return nil
+	}
+	return w.moduleForDir(pkg.Dir)
+}
+
+// Find the modules affected by a change to the given file: the modules
+// containing the packages returned by AffectedPackages.
+func (w *Watcher) AffectedModules(file string) []*Module {
+	seen := make(map[string]bool)
+	var mods []*Module
+	for _, path := range w.AffectedPackages([]string{file}) {
+		if mod := w.ModuleFor(path); mod != nil && !seen[mod.Path] {
+			seen[mod.Path] = true
+			mods = append(mods, mod)
+		}
+	}
+	sort.Slice(mods, func(i, j int) bool { return mods[i].Path < mods[j].Path })
+	return mods
+}
+
+// Find the module containing a directory, caching the result for the
+// module root.
+func (w *Watcher) moduleForDir(dir string) *Module {
+	for d := dir; ; d = filepath.Dir(d) {
+		w.mu.RLock()
+		mod, ok := w.modules[d]
+		w.mu.RUnlock()
+		if ok {
+			return mod
+		}
+		if mod = readModule(d); mod != nil {
+			w.mu.Lock()
+			w.modules[d] = mod
+			w.mu.Unlock()
+			return mod
+		}
+		if parent := filepath.Dir(d); parent == d {
+			return nil
+		}
+	}
+}
+
+// Read the module rooted at dir if there is one.
+func readModule(dir string) *Module {
+	// modules in the module cache live in path@version directories and
+	// may not have a go.mod file
+	var version, cachePath string
+	if i := strings.LastIndex(filepath.Base(dir), "@"); i > 0 {
+		version = unescapeModule(filepath.Base(dir)[i+1:])
+		if j := strings.Index(filepath.ToSlash(dir), "/pkg/mod/"); j >= 0 {
+			rel := filepath.ToSlash(dir)[j+len("/pkg/mod/"):]
+			cachePath = unescapeModule(rel[:strings.LastIndex(rel, "@")])
+		}
+	}
+	gomod := filepath.Join(dir, "go.mod")
+	if _, err := os.Stat(gomod); err != nil {
+		if cachePath == "" {
+			return nil
+		}
+		return &Module{Path: cachePath, Version: version, Dir: dir}
+	}
+	parsed, err := parseGoMod(gomod)
+	if err != nil || parsed.module == "" {
+		return nil
+	}
+	return &Module{Path: parsed.module, Version: version, Dir: dir}
+}
+
+// Undo the module cache escaping of upper case letters as !lower.
+func unescapeModule(s string) string {
+	var b strings.Builder
+	bang := false
+	for _, r := range s {
+		if bang {
+			r = unicode.ToUpper(r)
+			bang = false
+		} else if r == '!' {
+			bang = true
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
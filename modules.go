@@ -0,0 +1,253 @@
+package pkgwatcher
+
+import (
+	"bufio"
+	"go/build"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// A Go module. Packages and Deps are only set by DiscoverModules.
+type Module struct {
+	Path     string   // module path
+	Version  string   // version for modules in the module cache
+	Dir      string   // root directory of the module
+	Packages []string // import paths of the packages in the module
+	Deps     []string // paths of other discovered modules this one depends on
+}
+
+// Find all modules below root by looking for go.mod files. Dependency
+// edges between the discovered modules are derived from their require
+// and replace directives, and from a go.work file in root if present.
+func DiscoverModules(root string) ([]*Module, error) {
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+	var mods []*Module
+	byPath := make(map[string]*Module)
+	byDir := make(map[string]*Module)
+	requires := make(map[*Module][]string)
+	replaces := make(map[*Module][]string)
+	err = filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			if path != root && skipSourceDir(info.Name()) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if info.Name() != "go.mod" {
+			return nil
+		}
+		gomod, err := parseGoMod(path)
+		if err != nil {
+			return err
+		}
+		mod := &Module{Path: gomod.module, Dir: filepath.Dir(path)}
+		mods = append(mods, mod)
+		byPath[mod.Path] = mod
+		byDir[mod.Dir] = mod
+		requires[mod] = gomod.requires
+		for _, target := range gomod.replaces {
+			if !filepath.IsAbs(target) {
+				target = filepath.Join(mod.Dir, target)
+			}
+			replaces[mod] = append(replaces[mod], filepath.Clean(target))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var workspace []*Module
+	if uses, err := parseGoWork(filepath.Join(root, "go.work")); err == nil {
+		for _, use := range uses {
+			if mod := byDir[filepath.Join(root, use)]; mod != nil {
+				workspace = append(workspace, mod)
+			}
+		}
+	}
+	for _, mod := range mods {
+		deps := make(map[string]bool)
+		for _, path := range requires[mod] {
+			if byPath[path] != nil {
+				deps[path] = true
+			}
+		}
+		for _, dir := range replaces[mod] {
+			if dep := byDir[dir]; dep != nil {
+				deps[dep.Path] = true
+			}
+		}
+		var imports []string
+		mod.Packages, imports = modulePackages(mod)
+		// workspace modules may import each other without requiring
+		for _, ws := range workspace {
+			for _, path := range imports {
+				if path == ws.Path || strings.HasPrefix(path, ws.Path+"/") {
+					deps[ws.Path] = true
+				}
+			}
+		}
+		delete(deps, mod.Path)
+		for path := range deps {
+			mod.Deps = append(mod.Deps, path)
+		}
+		sort.Strings(mod.Deps)
+	}
+	return mods, nil
+}
+
+// Watch the packages of the given modules, resolving each from its own
+// module directory.
+func (w *Watcher) WatchModules(mods []*Module) {
+	for _, mod := range mods {
+		for _, path := range mod.Packages {
+			w.resolve(path, false, RootOptions{Dir: mod.Dir})
+		}
+	}
+	w.watchPackages()
+}
+
+// Find the packages of a module, excluding nested modules, along with
+// all the import paths they use.
+func modulePackages(mod *Module) (pkgs, imports []string) {
+	filepath.Walk(mod.Dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return nil
+		}
+		if !info.IsDir() {
+			return nil
+		}
+		if path != mod.Dir {
+			if skipSourceDir(info.Name()) {
+				return filepath.SkipDir
+			}
+			if _, err := os.Stat(filepath.Join(path, "go.mod")); err == nil {
+				return filepath.SkipDir
+			}
+		}
+		if !hasGoFiles(path) {
+			return nil
+		}
+		rel, _ := filepath.Rel(mod.Dir, path)
+		if rel == "." {
+			pkgs = append(pkgs, mod.Path)
+		} else {
+			pkgs = append(pkgs, mod.Path+"/"+filepath.ToSlash(rel))
+		}
+		if pkg, err := build.ImportDir(path, build.ImportComment); err == nil {
+			imports = append(imports, pkg.Imports...)
+		}
+		return nil
+	})
+	return pkgs, imports
+}
+
+// Check if a directory name is ignored by the go tool.
+func skipSourceDir(name string) bool {
+	return name == "testdata" || name == "vendor" ||
+		strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_")
+}
+
+// Check if a directory directly contains Go source files.
+func hasGoFiles(dir string) bool {
+	f, err := os.Open(dir)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	names, _ := f.Readdirnames(-1)
+	for _, name := range names {
+		if strings.HasSuffix(name, ".go") {
+			return true
+		}
+	}
+	return false
+}
+
+// The directives of a go.mod file relevant to discovery.
+type goMod struct {
+	module    string
+	requires  []string
+	replaces  []string // local replacement directories
+	goVersion string
+}
+
+// Parse the module, go, require and replace directives of a go.mod file.
+func parseGoMod(file string) (*goMod, error) {
+	gomod := &goMod{}
+	err := eachDirective(file, func(verb string, args []string) {
+		switch verb {
+		case "module":
+			if len(args) > 0 {
+				gomod.module = strings.Trim(args[0], `"`)
+			}
+		case "go":
+			if len(args) > 0 {
+				gomod.goVersion = args[0]
+			}
+		case "require":
+			if len(args) > 0 {
+				gomod.requires = append(gomod.requires, strings.Trim(args[0], `"`))
+			}
+		case "replace":
+			for i, arg := range args {
+				if arg == "=>" && i+1 < len(args) {
+					target := args[i+1]
+					if strings.HasPrefix(target, ".") || filepath.IsAbs(target) {
+						gomod.replaces = append(gomod.replaces, target)
+					}
+				}
+			}
+		}
+	})
+	return gomod, err
+}
+
+// Parse the use directives of a go.work file.
+func parseGoWork(file string) ([]string, error) {
+	var uses []string
+	err := eachDirective(file, func(verb string, args []string) {
+		if verb == "use" && len(args) > 0 {
+			uses = append(uses, strings.Trim(args[0], `"`))
+		}
+	})
+	return uses, err
+}
+
+// Call f for every directive in a go.mod style file, expanding blocks.
+func eachDirective(file string, f func(verb string, args []string)) error {
+	r, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	var block string
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		line := s.Text()
+		if i := strings.Index(line, "//"); i >= 0 {
+			line = line[:i]
+		}
+		fields := strings.Fields(line)
+		switch {
+		case len(fields) == 0:
+		case block != "" && fields[0] == ")":
+			block = ""
+		case block != "":
+			f(block, fields)
+		case len(fields) == 2 && fields[1] == "(":
+			block = fields[0]
+		default:
+			f(fields[0], fields[1:])
+		}
+	}
+	return s.Err()
+}
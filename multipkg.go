@@ -0,0 +1,94 @@
+package pkgwatcher
+
+import (
+	"go/build"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Import each of the packages in a directory holding more than one,
+// such as a main package next to a library during a migration. The
+// library, if any, comes first. Returns nil if none could be imported.
+func (w *Watcher) splitPackages(mp *build.MultiplePackageError, importPath string) []*build.Package {
+	w.mu.RLock()
+	ctxt := w.context
+	w.mu.RUnlock()
+	var names []string
+	for _, name := range mp.Packages {
+		if name != "main" && !containsString(names, name) {
+			names = append(names, name)
+		}
+	}
+	if containsString(mp.Packages, "main") {
+		names = append(names, "main")
+	}
+	var pkgs []*build.Package
+	for _, name := range names {
+		c := ctxt
+		c.ReadDir = packageReadDir(mp.Dir, name)
+		pkg, err := c.ImportDir(mp.Dir, build.AllowBinary)
+		if err != nil {
+			continue
+		}
+		pkg.ImportPath = importPath
+		pkgs = append(pkgs, pkg)
+	}
+	return pkgs
+}
+
+// A ReadDir function hiding the Go files of dir that belong to packages
+// other than the named one or its external tests.
+func packageReadDir(dir, name string) func(string) ([]os.FileInfo, error) {
+	return func(path string) ([]os.FileInfo, error) {
+		entries, err := ioutil.ReadDir(path)
+		if err != nil || path != dir {
+			return entries, err
+		}
+		var kept []os.FileInfo
+		for _, info := range entries {
+			if !info.IsDir() && filepath.Ext(info.Name()) == ".go" {
+				clause, ok := packageClause(filepath.Join(dir, info.Name()))
+				if ok && clause != name && clause != name+"_test" {
+					continue
+				}
+			}
+			kept = append(kept, info)
+		}
+		return kept, nil
+	}
+}
+
+// The package a Go file belongs to when its directory holds more than
+// one, based on its package clause. Otherwise returns pkg.
+func (w *Watcher) filePackage(pkg *build.Package, file string) *build.Package {
+	if filepath.Ext(file) != ".go" || filepath.Dir(file) != pkg.Dir {
+		return pkg
+	}
+	pkgs := w.dirs.all(pkg.Dir)
+	if len(pkgs) < 2 {
+		return pkg
+	}
+	clause, ok := packageClause(file)
+	if !ok {
+		return pkg
+	}
+	clause = strings.TrimSuffix(clause, "_test")
+	for _, p := range pkgs {
+		if p.Name == clause {
+			return p
+		}
+	}
+	return pkg
+}
+
+// Check if an unsorted slice contains a string.
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,60 @@
+package pkgwatcher
+
+import (
+	"sync"
+)
+
+// Packages whose events are temporarily dropped.
+type muter struct {
+	mu      sync.Mutex
+	dropped map[string]int // import path -> events dropped while muted
+}
+
+// Stop delivering events for the package with the given import path. The
+// package stays watched and part of the dependency graph, and its events
+// are counted in Stats until it is unmuted.
+func (w *Watcher) Mute(importPath string) {
+	w.muter.mu.Lock()
+	defer w.muter.mu.Unlock()
+	if w.muter.dropped == nil {
+		w.muter.dropped = make(map[string]int)
+	}
+	if _, ok := w.muter.dropped[importPath]; !ok {
+		w.muter.dropped[importPath] = 0
+	}
+}
+
+// Resume delivering events for a muted package.
+func (w *Watcher) Unmute(importPath string) {
+	w.muter.mu.Lock()
+	defer w.muter.mu.Unlock()
+	delete(w.muter.dropped, importPath)
+}
+
+// Check if an event is for a muted package, counting it as dropped.
+func (w *Watcher) muted(ev *Event) bool {
+	if ev.Package == nil {
+		return false
+	}
+	w.muter.mu.Lock()
+	defer w.muter.mu.Unlock()
+	n, ok := w.muter.dropped[ev.Package.ImportPath]
+	if ok {
+		w.muter.dropped[ev.Package.ImportPath] = n + 1
+	}
+	return ok
+}
+
+// The muted packages and the number of events dropped for each.
+func (w *Watcher) mutedCounts() map[string]int {
+	w.muter.mu.Lock()
+	defer w.muter.mu.Unlock()
+	if len(w.muter.dropped) == 0 {
+		return nil
+	}
+	counts := make(map[string]int, len(w.muter.dropped))
+	for path, n := range w.muter.dropped {
+		counts[path] = n
+	}
+	return counts
+}
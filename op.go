@@ -0,0 +1,94 @@
+package pkgwatcher
+
+import (
+	"fmt"
+	"github.com/howeyc/fsnotify"
+	"strings"
+)
+
+// The operations a file event describes.
+type Op uint32
+
+const (
+	OpCreate Op = 1 << iota
+	OpModify
+	OpDelete
+	OpRename
+	OpAttrib
+)
+
+var opNames = []struct {
+	op   Op
+	name string
+}{
+	{OpCreate, "create"},
+	{OpModify, "write"},
+	{OpDelete, "delete"},
+	{OpRename, "rename"},
+	{OpAttrib, "chmod"},
+}
+
+func (op Op) String() string {
+	var names []string
+	for _, n := range opNames {
+		if op&n.op != 0 {
+			names = append(names, n.name)
+		}
+	}
+	return strings.Join(names, "|")
+}
+
+// Parse an Op in the form returned by Op.String.
+func ParseOp(s string) (Op, error) {
+	var op Op
+	if s == "" {
+		return op, nil
+	}
+parts:
+	for _, part := range strings.Split(s, "|") {
+		for _, n := range opNames {
+			if part == n.name {
+				op |= n.op
+				continue parts
+			}
+		}
+		return 0, fmt.Errorf("Invalid op %q", part)
+	}
+	return op, nil
+}
+
+// The operations of an underlying file event.
+func fileOp(fe *fsnotify.FileEvent) Op {
+	var op Op
+	if fe.IsCreate() {
+		op |= OpCreate
+	}
+	if fe.IsModify() {
+		op |= OpModify
+	}
+	if fe.IsDelete() {
+		op |= OpDelete
+	}
+	if fe.IsRename() {
+		op |= OpRename
+	}
+	if fe.IsAttrib() {
+		op |= OpAttrib
+	}
+	return op
+}
+
+// Check if the event is for a file creation.
+func (e *Event) IsCreate() bool { return e.Op&OpCreate != 0 }
+
+// Check if the event is for a file modification.
+func (e *Event) IsModify() bool { return e.Op&OpModify != 0 }
+
+// Check if the event is for a file deletion.
+func (e *Event) IsDelete() bool { return e.Op&OpDelete != 0 }
+
+// Check if the event is for a file rename.
+func (e *Event) IsRename() bool { return e.Op&OpRename != 0 }
+
+// Check if the event is for a change of file attributes.
+func (e *Event) IsAttrib() bool { return e.Op&OpAttrib != 0 }
@@ -0,0 +1,44 @@
+package pkgwatcher
+
+import (
+	"testing"
+)
+
+func TestParseOp(t *testing.T) {
+	cases := []struct {
+		s  string
+		op Op
+	}{
+		{"", 0},
+		{"create", OpCreate},
+		{"write", OpModify},
+		{"delete", OpDelete},
+		{"rename", OpRename},
+		{"chmod", OpAttrib},
+		{"create|write", OpCreate | OpModify},
+		{"rename|delete", OpDelete | OpRename},
+	}
+	for _, c := range cases {
+		op, err := ParseOp(c.s)
+		if err != nil || op != c.op {
+			t.Errorf("ParseOp(%q) = %v, %v, want %v", c.s, op, err, c.op)
+		}
+	}
+}
+
+func TestParseOpErrors(t *testing.T) {
+	for _, s := range []string{"move", "create|", "|write", "Create", "create write"} {
+		if op, err := ParseOp(s); err == nil {
+			t.Errorf("ParseOp(%q) = %v, want an error", s, op)
+		}
+	}
+}
+
+func TestOpStringRoundTrip(t *testing.T) {
+	for op := Op(0); op < OpAttrib<<1; op++ {
+		parsed, err := ParseOp(op.String())
+		if err != nil || parsed != op {
+			t.Errorf("ParseOp(%q) = %v, %v, want %v", op.String(), parsed, err, op)
+		}
+	}
+}
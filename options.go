@@ -0,0 +1,121 @@
+package pkgwatcher
+
+import (
+	"github.com/howeyc/fsnotify"
+	"go/build"
+	"time"
+)
+
+// Resolves import paths to packages. A *build.Context is a Resolver.
+type Resolver interface {
+	Import(path string, srcDir string, mode build.ImportMode) (*build.Package, error)
+}
+
+// An Option configures a Watcher created by New.
+type Option func(*Watcher)
+
+// Watch the given import paths.
+func WithImportPaths(importPaths ...string) Option {
+	return func(w *Watcher) {
+		w.initialPaths = append(w.initialPaths, importPaths...)
+	}
+}
+
+// Resolve import paths relative to the given directory instead of the
+// current working directory.
+func WithWorkingDir(dir string) Option {
+	return func(w *Watcher) {
+		if dir != "" {
+			w.workingDirectory = dir
+		}
+	}
+}
+
+// Coalesce events for the same file arriving within the given delay,
+// delivering only the latest one.
+func WithDebounce(d time.Duration) Option {
+	return func(w *Watcher) {
+		w.debouncer.delay = d
+	}
+}
+
+// Deliver events for the packages with the given import paths without
+// waiting for the debounce delay.
+func WithLowLatency(importPaths ...string) Option {
+	return func(w *Watcher) {
+		w.debouncer.fast = lowLatencySet(importPaths)
+	}
+}
+
+// Resolve import paths using the given Resolver instead of the build
+// context. RootOptions.Tags are ignored when a Resolver is set.
+func WithResolver(r Resolver) Option {
+	return func(w *Watcher) {
+		w.resolver = r
+	}
+}
+
+// Use the given Backend instead of fsnotify.
+func WithBackend(b Backend) Option {
+	return func(w *Watcher) {
+		w.backend = b
+	}
+}
+
+// Also deliver every backend event unmodified on the RawEvent channel,
+// before it is filtered or augmented. The channel buffers up to n events
+// and must be drained, or event delivery stops.
+func WithRawEvents(n int) Option {
+	return func(w *Watcher) {
+		w.RawEvent = make(chan *fsnotify.FileEvent, n)
+	}
+}
+
+// Buffer up to n events on the Event channel.
+func WithBufferSize(n int) Option {
+	return func(w *Watcher) {
+		w.Event = make(chan *Event, n)
+	}
+}
+
+// Create a new Watcher configured by the given options.
+func New(opts ...Option) (*Watcher, error) {
+	w := newWatcher("")
+	for _, opt := range opts {
+		opt(w)
+	}
+	if w.backend == nil {
+		b, err := NewFsnotifyBackend()
+		if err != nil {
+			return nil, err
+		}
+		w.backend = b
+	}
+	w.start()
+	return w, nil
+}
+
+// The Resolver to use for a root with the given options.
+func (w *Watcher) resolverFor(opts RootOptions) Resolver {
+	w.mu.RLock()
+	ctxt := w.context
+	w.mu.RUnlock()
+	return w.contextResolver(ctxt, opts)
+}
+
+// The Resolver to use for a root with the given options in the given
+// build context.
+func (w *Watcher) contextResolver(ctxt build.Context, opts RootOptions) Resolver {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	if w.resolver != nil {
+		return w.resolver
+	}
+	if len(opts.Tags) > 0 {
+		ctxt.BuildTags = append(append([]string(nil), ctxt.BuildTags...), opts.Tags...)
+	}
+	if w.effectiveMode() == ModuleMode {
+		return moduleResolver{&ctxt}
+	}
+	return &ctxt
+}
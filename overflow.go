@@ -0,0 +1,78 @@
+package pkgwatcher
+
+import (
+	"sort"
+	"sync"
+)
+
+// Describes events dropped because the Event channel was full. After an
+// overflow, consumers should reconcile their state instead of trusting
+// the incremental stream.
+type Overflow struct {
+	Count    int      // number of dropped events
+	Packages []string // import paths of the packages the events were for
+}
+
+// Tracks dropped events when the drop policy is enabled.
+type dropper struct {
+	mu       sync.Mutex
+	enabled  bool
+	count    int
+	packages map[string]bool
+}
+
+// Drop events instead of blocking when the Event channel is full. An
+// Event with Overflow set is delivered once the channel has room again,
+// ahead of the next event. Most useful along with WithBufferSize.
+func (w *Watcher) SetDropWhenFull(enabled bool) {
+	w.dropper.mu.Lock()
+	defer w.dropper.mu.Unlock()
+	w.dropper.enabled = enabled
+}
+
+// Drop events instead of blocking when the Event channel is full.
+func WithDropWhenFull() Option {
+	return func(w *Watcher) {
+		w.dropper.enabled = true
+	}
+}
+
+// Try to send an event without blocking, recording it as dropped if
+// there is no room. Returns false if the drop policy is disabled.
+func (w *Watcher) trySend(ev *Event) bool {
+	d := &w.dropper
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if !d.enabled {
+		return false
+	}
+	if d.count > 0 {
+		overflow := &Overflow{Count: d.count}
+		for path := range d.packages {
+			overflow.Packages = append(overflow.Packages, path)
+		}
+		sort.Strings(overflow.Packages)
+		select {
+		case w.Event <- &Event{Overflow: overflow, Time: ev.Time}:
+			d.count = 0
+			d.packages = nil
+		default:
+		}
+	}
+	if d.count == 0 {
+		select {
+		case w.Event <- ev:
+			w.history.add(ev)
+			return true
+		default:
+		}
+	}
+	d.count++
+	if ev.Package != nil {
+		if d.packages == nil {
+			d.packages = make(map[string]bool)
+		}
+		d.packages[ev.Package.ImportPath] = true
+	}
+	return true
+}
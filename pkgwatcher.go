@@ -3,129 +3,694 @@
 package pkgwatcher
 
 import (
+	"context"
 	"fmt"
 	"github.com/howeyc/fsnotify"
 	"go/build"
+	"io/ioutil"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
 )
 
-// File level changes including the package that contains it.
+// File level changes including the package that contains it. The Name
+// of the file is always absolute and clean.
 type Event struct {
 	*fsnotify.FileEvent
+	Op         Op // set from the FileEvent unless already set
+	Package    *build.Package
+	ImportPath string  // import path of the Package, see Standard and XTest
+	Module     *Module // module containing the Package in module mode
+	RelPath    string  // file name relative to the Package directory
+	Time       time.Time
+	Targets    []string  // build targets the file is part of, see AddTarget
+	AssetDir   string    // set if the file is in one of the package asset dirs
+	NoImpact   bool      // set by analysis if dependents can not be affected
+	Standard   bool      // set for files under GOROOT/src, ImportPath is the file's directory
+	XTest      bool      // set for external test files, ImportPath has a _test suffix
+	Overflow   *Overflow // set instead of FileEvent if events were dropped
+
+	Annotations map[string]string // added by hooks
+
+	trace *eventTrace
+}
+
+// The kind of a PackageEvent.
+type PackageEventKind int
+
+const (
+	// No file in the package has changed for the quiet period.
+	PackageSettled PackageEventKind = iota
+	// The package directory is gone, see SetTrackPackages.
+	PackageRemoved
+	// A source file was added to the package.
+	FileAdded
+	// A source file was removed from the package.
+	FileRemoved
+	// The package was checked after a change to one of its Go files.
+	PackageDiagnostics
+	// The go toolchain changed. Package is not set.
+	ToolchainChanged
+	// The exported API of the package changed.
+	APIChanged
+	// The package was added to the watch set during resolution.
+	PackageAdded
+	// The package is resolved but not watched, see Reason.
+	PackageSkipped
+	// Resolution changed the import graph. Package is not set.
+	GraphChanged
+)
+
+func (k PackageEventKind) String() string {
+	switch k {
+	case PackageSettled:
+		return "PackageSettled"
+	case PackageRemoved:
+		return "PackageRemoved"
+	case FileAdded:
+		return "FileAdded"
+	case FileRemoved:
+		return "FileRemoved"
+	case PackageDiagnostics:
+		return "PackageDiagnostics"
+	case ToolchainChanged:
+		return "ToolchainChanged"
+	case APIChanged:
+		return "APIChanged"
+	case PackageAdded:
+		return "PackageAdded"
+	case PackageSkipped:
+		return "PackageSkipped"
+	case GraphChanged:
+		return "GraphChanged"
+	}
+	return fmt.Sprintf("PackageEventKind(%d)", int(k))
+}
+
+// Package level changes.
+type PackageEvent struct {
+	Kind    PackageEventKind
 	Package *build.Package
+	File    string      // file name for FileAdded and FileRemoved
+	Errors  []error     // parse or type errors for PackageDiagnostics
+	API     *APIDiff    // changed symbols for APIChanged
+	Reason  string      // why the package is not watched for PackageSkipped
+	Graph   *GraphDelta // edges added and removed for GraphChanged
+	Time    time.Time
 }
 
 // A Watcher exposes events via channels notifying on changes in
 // monitored packages.
 type Watcher struct {
-	Packages           map[string]*build.Package // indexed by pkg.ImportPath
-	DirPackages        map[string]*build.Package // indexed by pkg.Dir
+	// Deprecated: updated concurrently with readers, use PackageList.
+	Packages map[string]*build.Package // indexed by pkg.ImportPath
+	// Deprecated: updated concurrently with readers, use PackageList.
+	DirPackages map[string]*build.Package // indexed by pkg.Dir
+
 	Event              chan *Event
+	PackageEvent       chan *PackageEvent
+	RawEvent           chan *fsnotify.FileEvent // nil unless WithRawEvents is used
+	Failure            chan *Failure            // nil unless WithFailures is used
 	Error              chan error
 	workingDirectory   string
+	context            build.Context
 	watchedDirectories map[string]bool
-	fsnotify           *fsnotify.Watcher
+	watchedFiles       map[string]bool // with per file watching
+	backend            Backend
+	resolver           Resolver
+	mode               Mode
+	initialPaths       []string
+	injector           injector
+	history            history
+	debouncer          debouncer
+	dropper            dropper
+	settler            settler
+	poller             poller
+	analysis           analysis
+	remover            remover
+	retrier            retrier
+	muter              muter
+	diagnoser          diagnoser
+	toolchain          toolchain
+	trackFiles         bool
+	trackPackages      bool
+	trackGraph         bool
+	tracing            bool
+	instrumentation    Instrumentation
+	clock              Clock
+	reducedDeps        bool
+	targets            []*target
+	includePrefixes    []string
+	excludePrefixes    []string
+	fileWatches        bool
+	rootTestData       bool
+	mainFile           string // set by WatchMainFile
+	skipGoroot         bool
+	errorHandler       func(error)
+	errorTimeout       time.Duration
+	errorsUnread       bool // an error timed out waiting for a reader
+	filter             Filter
+	chmodEvents        bool
+	hooks              [][]string
+	hookTimeout        time.Duration
+	hookContext        context.Context // cancelled on Close
+	cancelHooks        context.CancelFunc
+	ready              bool
+	blocked            int
+	blockedSince       time.Time
+	backendClosed      bool  // the Backend closed its event channel
+	backendErr         error // the last error from the Backend
+	mu                 sync.RWMutex
+	assetDirs          []string
+	skipDirs           []string
+	hidden             HiddenPolicy
+	hiddenExceptions   []string
+	depth              map[string]int       // distance from a root by import path
+	expanded           map[string]expansion // imports followed by import path
+	roots              map[string]RootOptions
+	modules            map[string]*Module // by module root
+	dirs               dirIndex           // DirPackages for the event path
+	watches            int
+	removals           int // since the maps were last compacted
+	names              interner
 	done               chan bool
+	closeOnce          sync.Once
+	running            sync.WaitGroup // goroutines Close waits for
+	spawning           sync.Mutex     // orders spawn with Close
 }
 
 // Create a new Watcher that monitors all the given import paths. If a
 // working directory is not specified, the current working directory
 // will be used.
 func NewWatcher(importPaths []string, wd string) (w *Watcher, err error) {
+	return New(WithImportPaths(importPaths...), WithWorkingDir(wd))
+}
+
+// Start monitoring.
+func (w *Watcher) start() {
+	w.spawn(func() { w.supervise("event proxy", w.proxyEvent) })
+	w.spawn(func() { w.supervise("error forwarder", w.forwardErrors) })
+	w.spawn(func() { w.supervise("poller", w.poll) })
+	w.spawn(func() { w.supervise("resolution retrier", w.retry) })
+	w.spawn(func() {
+		w.protect("watching toolchain", w.watchToolchain)
+		for _, p := range w.initialPaths {
+			w.protect("resolving "+p, func() { w.WatchImportPath(p, false) })
+		}
+		w.mu.Lock()
+		w.ready = true
+		w.mu.Unlock()
+	})
+}
+
+// Run f in a goroutine that Close waits for, unless the Watcher is
+// already closed.
+func (w *Watcher) spawn(f func()) {
+	w.spawning.Lock()
+	defer w.spawning.Unlock()
+	if w.closed() {
+		return
+	}
+	w.running.Add(1)
+	go func() {
+		defer w.running.Done()
+		f()
+	}()
+}
+
+// Check if the Watcher was closed.
+func (w *Watcher) closed() bool {
+	select {
+	case <-w.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// Send a PackageEvent unless the Watcher is closed.
+func (w *Watcher) sendPackageEvent(pe *PackageEvent) {
+	select {
+	case w.PackageEvent <- pe:
+	case <-w.done:
+	}
+}
+
+// Create a Watcher without starting any monitoring.
+func newWatcher(wd string) *Watcher {
 	if wd == "" {
+		var err error
 		wd, err = os.Getwd()
 		if err != nil {
 			wd = "/"
 		}
 	}
-	w = &Watcher{
+	w := &Watcher{
 		workingDirectory:   wd,
+		context:            ContextFromEnv(),
 		Packages:           make(map[string]*build.Package),
 		DirPackages:        make(map[string]*build.Package),
 		watchedDirectories: make(map[string]bool),
 		Event:              make(chan *Event),
+		PackageEvent:       make(chan *PackageEvent),
+		Error:              make(chan error),
+		assetDirs:          DefaultAssetDirs,
+		skipDirs:           DefaultSkipDirs,
+		depth:              make(map[string]int),
+		expanded:           make(map[string]expansion),
+		roots:              make(map[string]RootOptions),
+		modules:            make(map[string]*Module),
+		done:               make(chan bool),
+		clock:              RealClock,
 	}
-	w.fsnotify, err = fsnotify.NewWatcher()
-	if err != nil {
-		return nil, err
-	}
-	w.Error = w.fsnotify.Error
-	go w.proxyEvent()
-	go func() {
-		for _, p := range importPaths {
-			w.WatchImportPath(p, false)
-		}
-	}()
-	return w, nil
+	w.hookContext, w.cancelHooks = context.WithCancel(context.Background())
+	w.hookTimeout = DefaultHookTimeout
+	w.errorTimeout = DefaultErrorTimeout
+	w.history.resize(DefaultHistorySize)
+	w.poller.interval = DefaultPollInterval
+	w.remover.grace = DefaultRemovalGrace
+	w.retrier.interval = DefaultRetryInterval
+	w.retrier.kick = make(chan bool, 1)
+	w.injector.notify = make(chan bool, 1)
+	return w
+}
+
+// Options controlling how a root import path and its dependencies are
+// resolved.
+type RootOptions struct {
+	MaxDepth int      // levels of imports to follow, 0 for all, negative for none
+	Tests    bool     // include the imports of the root package's tests
+	Tags     []string // build tags in addition to those of the build context
+	Dir      string   // directory to resolve from instead of the working directory
 }
 
 // Watch import paths.
 func (w *Watcher) WatchImportPath(importPath string, force bool) {
-	if importPath == "C" {
-		return
+	w.WatchRoot(importPath, force, RootOptions{})
+}
+
+// Watch an import path resolving it and its dependencies according to
+// the given options.
+func (w *Watcher) WatchRoot(importPath string, force bool, opts RootOptions) {
+	defer w.traceResolve(importPath)()
+	var before map[Edge]bool
+	if w.tracksGraph() {
+		before = w.edges()
 	}
-	if !force && w.Packages[importPath] != nil {
-		return
+	w.resolve(importPath, force, opts)
+	w.watchPackages()
+	if before != nil {
+		w.graphChanged(before)
 	}
-	pkg, err := build.Import(importPath, w.workingDirectory, build.AllowBinary)
-	if err != nil {
-		w.Error <- fmt.Errorf(
-			"Failed to find import path %s with error %s", importPath, err)
-		return
+}
+
+// Resolve an import path and its dependencies breadth first, recording
+// the distance of each package from a root.
+func (w *Watcher) resolve(importPath string, force bool, opts RootOptions) {
+	w.mu.Lock()
+	w.roots[importPath] = opts
+	var targets []*target
+	if w.resolver == nil {
+		targets = append(targets, w.targets...)
+	}
+	w.mu.Unlock()
+	w.resolveWith(w.resolverFor(opts), nil, importPath, 0, force, opts)
+	for _, t := range targets {
+		w.resolveWith(w.contextResolver(t.context, opts), t, importPath, 0, force, opts)
+	}
+}
+
+// Resolve an import path at the given distance from a root and its
+// dependencies with the given Resolver. Packages resolved for a target
+// are recorded as part of it, and only added to the watched packages if
+// missing, so the main build context wins.
+func (w *Watcher) resolveWith(resolver Resolver, t *target, importPath string, depth int, force bool, opts RootOptions) {
+	type item struct {
+		importPath string
+		depth      int
+	}
+	srcDir := w.workingDirectory
+	if opts.Dir != "" {
+		srcDir = opts.Dir
+	}
+	seen := make(map[string]bool)
+	queue := []item{{importPath, depth}}
+	for len(queue) > 0 && !w.closed() {
+		it := queue[0]
+		queue = queue[1:]
+		if it.importPath == "C" {
+			continue
+		}
+		want := wantExpansion(opts, it.depth)
+		w.mu.Lock()
+		pkg := w.Packages[it.importPath]
+		if pkg != nil && it.depth < w.depth[pkg.ImportPath] {
+			w.depth[pkg.ImportPath] = it.depth
+		}
+		// a resolved package is only expanded again if this root reaches
+		// it with more levels of imports to follow or wants its tests
+		reuse := t == nil && pkg != nil && (!force || it.depth > 0)
+		if reuse && w.expanded[pkg.ImportPath].covers(want) {
+			w.mu.Unlock()
+			continue
+		}
+		w.mu.Unlock()
+		if t != nil {
+			if seen[it.importPath] {
+				continue
+			}
+			seen[it.importPath] = true
+		}
+		pkgs := []*build.Package{pkg}
+		if reuse {
+			pkgs = w.samePackages(pkg)
+		} else {
+			var err error
+			pkg, err = resolver.Import(
+				it.importPath, srcDir, build.AllowBinary)
+			pkgs = []*build.Package{pkg}
+			if mp, ok := err.(*build.MultiplePackageError); ok && w.resolver == nil {
+				if pkgs = w.splitPackages(mp, it.importPath); len(pkgs) > 0 {
+					pkg, err = pkgs[0], nil
+				}
+			}
+			if err != nil {
+				if !w.retrier.add(it.importPath, importPath) {
+					w.reportError(fmt.Errorf(
+						"Failed to find import path %s with error %s", it.importPath, err))
+				}
+				continue
+			}
+		}
+		w.mu.Lock()
+		if t != nil {
+			t.packages[pkg.ImportPath] = true
+		}
+		added := w.Packages[pkg.ImportPath] == nil
+		if t == nil || added {
+			w.Packages[pkg.ImportPath] = pkg
+			w.DirPackages[pkg.Dir] = pkg
+			w.dirs.setAll(pkg.Dir, pkgs)
+		}
+		if d, ok := w.depth[pkg.ImportPath]; !ok || it.depth < d {
+			w.depth[pkg.ImportPath] = it.depth
+		}
+		if t == nil {
+			w.expanded[pkg.ImportPath] = w.expanded[pkg.ImportPath].merge(want)
+		}
+		track := w.trackPackages
+		w.mu.Unlock()
+		if added && track {
+			w.sendPackageEvent(&PackageEvent{
+				Kind:    PackageAdded,
+				Package: pkg,
+				Time:    w.clock.Now(),
+			})
+		}
+		if want.levels == 0 {
+			continue
+		}
+		var imports []string
+		for _, p := range pkgs {
+			imports = append(imports, p.Imports...)
+			if want.tests {
+				imports = append(append(imports, p.TestImports...), p.XTestImports...)
+			}
+		}
+		for _, path := range imports {
+			if w.follows(path) {
+				queue = append(queue, item{path, it.depth + 1})
+			}
+		}
 	}
-	w.Packages[pkg.ImportPath] = pkg
-	w.DirPackages[pkg.Dir] = pkg
-	for _, path := range pkg.Imports {
-		w.WatchImportPath(path, false)
+}
+
+// How far the imports of a package have been followed.
+type expansion struct {
+	levels int  // levels of imports followed, allLevels for all
+	tests  bool // the imports of its tests were followed
+}
+
+const allLevels = int(^uint(0) >> 1)
+
+// The expansion wanted for a package at the given distance from a root.
+func wantExpansion(opts RootOptions, depth int) expansion {
+	e := expansion{levels: allLevels, tests: opts.Tests && depth == 0}
+	if opts.MaxDepth < 0 {
+		e.levels = 0
+	} else if opts.MaxDepth > 0 {
+		e.levels = opts.MaxDepth - depth
+		if e.levels < 0 {
+			e.levels = 0
+		}
+	}
+	return e
+}
+
+// Check if an expansion includes another.
+func (e expansion) covers(o expansion) bool {
+	return e.levels >= o.levels && (e.tests || !o.tests)
+}
+
+// Combine two expansions.
+func (e expansion) merge(o expansion) expansion {
+	if o.levels > e.levels {
+		e.levels = o.levels
+	}
+	e.tests = e.tests || o.tests
+	return e
+}
+
+// The packages sharing the directory and import path of a resolved
+// package, more than one if the directory was split.
+func (w *Watcher) samePackages(pkg *build.Package) []*build.Package {
+	var pkgs []*build.Package
+	for _, p := range w.dirs.all(pkg.Dir) {
+		if p.ImportPath == pkg.ImportPath {
+			pkgs = append(pkgs, p)
+		}
+	}
+	if len(pkgs) == 0 {
+		pkgs = []*build.Package{pkg}
+	}
+	return pkgs
+}
+
+// Watch the directories of all resolved packages, nearest to a root
+// first so they get priority within the watch budget.
+func (w *Watcher) watchPackages() {
+	for _, pkg := range w.packagesByDepth() {
+		if w.closed() {
+			return
+		}
+		w.watchPackage(pkg)
+		w.analyzePackage(pkg)
+		w.recordAPI(pkg)
 	}
+}
+
+// The resolved packages, nearest to a root first.
+func (w *Watcher) packagesByDepth() []*build.Package {
+	w.mu.RLock()
+	pkgs := make([]*build.Package, 0, len(w.Packages))
+	depth := make(map[string]int, len(w.Packages))
 	for _, pkg := range w.Packages {
-		w.WatchDirectory(pkg.Dir)
+		pkgs = append(pkgs, pkg)
+		depth[pkg.ImportPath] = w.depth[pkg.ImportPath]
 	}
+	w.mu.RUnlock()
+	sort.Slice(pkgs, func(i, j int) bool {
+		di, dj := depth[pkgs[i].ImportPath], depth[pkgs[j].ImportPath]
+		if di != dj {
+			return di < dj
+		}
+		return pkgs[i].ImportPath < pkgs[j].ImportPath
+	})
+	return pkgs
 }
 
-// Watch a directory including it's subdirectories.
+// Watch the directories of a package.
+func (w *Watcher) watchPackage(pkg *build.Package) {
+	if reason := w.skipReason(pkg); reason != "" {
+		w.skipped(pkg, reason)
+		return
+	}
+	if w.usesFileWatches() || pkg.ImportPath == MainImportPath {
+		w.watchFiles(pkg)
+		return
+	}
+	if w.isReduced(pkg) {
+		w.watchPath(pkg.Dir)
+		return
+	}
+	w.WatchDirectory(pkg.Dir)
+	w.watchAssetDirs(pkg.Dir)
+	w.watchTestData(pkg)
+}
+
+// Watch a directory including it's subdirectories. Top level
+// subdirectories are walked concurrently.
 func (w *Watcher) WatchDirectory(dir string) {
-	if w.watchedDirectories[dir] {
+	dir = w.absPath(dir)
+	w.mu.RLock()
+	watched := w.watchedDirectories[dir]
+	w.mu.RUnlock()
+	if watched {
+		return
+	}
+
+	info, err := os.Lstat(dir)
+	if err == nil && !info.IsDir() {
+		return
+	}
+	var entries []os.FileInfo
+	if err == nil {
+		if w.skipDir(info) {
+			return
+		}
+		entries, err = ioutil.ReadDir(dir)
+	}
+	if err != nil {
+		w.reportError(fmt.Errorf(
+			"Got error when walking directory %s with error %s", dir, err))
 		return
 	}
+	w.watchPath(dir)
+
+	var wg sync.WaitGroup
+	walkers := make(chan bool, maxWalkers)
+	for _, info := range entries {
+		if !info.IsDir() || w.skipName(info.Name()) {
+			continue
+		}
+		wg.Add(1)
+		go func(path string) {
+			walkers <- true
+			defer func() { <-walkers; wg.Done() }()
+			w.walk(dir, path)
+		}(filepath.Join(dir, info.Name()))
+	}
+	wg.Wait()
+}
+
+// Maximum number of concurrent directory walkers per WatchDirectory.
+const maxWalkers = 8
 
-	filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+// Serially walk and watch a subtree of dir.
+func (w *Watcher) walk(dir, root string) {
+	filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
-			w.Error <- fmt.Errorf(
+			w.reportError(fmt.Errorf(
 				"Got error when walking directory %s with entry %s and error %s",
-				dir, path, err)
+				dir, path, err))
 			return nil
 		}
 		if !info.IsDir() {
 			return nil
 		}
-		// TODO remove this surprise
-		if filepath.Base(info.Name())[0] == '.' {
+		if w.skipDir(info) || w.skipName(info.Name()) {
 			return filepath.SkipDir
 		}
-		if w.watchedDirectories[path] {
-			return nil
-		}
-		err = w.fsnotify.Watch(path)
-		if err != nil {
-			w.Error <- fmt.Errorf("Error watching %s: %s", path, err)
-		}
-		w.watchedDirectories[path] = true
+		w.watchPath(path)
 		return nil
 	})
 }
 
-// Close the Watcher.
+// Check if a directory should not be walked.
+func (w *Watcher) skipDir(info os.FileInfo) bool {
+	return w.skipHidden(filepath.Base(info.Name()), true)
+}
+
+// Directories not walked into when watching a directory. They are
+// still watched when passed to WatchDirectory directly.
+var DefaultSkipDirs = []string{"testdata", "vendor", "_obj", "_test"}
+
+// Set the names of the subdirectories to skip when walking a watched
+// directory, replacing DefaultSkipDirs.
+func (w *Watcher) SetSkipDirs(names ...string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.skipDirs = append([]string(nil), names...)
+}
+
+// Check if a subdirectory name is in the skip list.
+func (w *Watcher) skipName(name string) bool {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	for _, skip := range w.skipDirs {
+		if name == skip {
+			return true
+		}
+	}
+	return false
+}
+
+// Add a watch for a single directory unless it is already watched.
+func (w *Watcher) watchPath(path string) {
+	w.mu.Lock()
+	if w.watchedDirectories[path] {
+		w.mu.Unlock()
+		return
+	}
+	w.watchedDirectories[path] = true
+	if !w.poller.allows(w.watches) {
+		w.mu.Unlock()
+		w.poller.add(path)
+		return
+	}
+	w.watches++
+	w.mu.Unlock()
+	err := w.backend.Watch(path)
+	if err != nil {
+		err = fmt.Errorf("Error watching %s: %s", path, err)
+		w.reportError(err)
+		w.fail(WatchFailed, nil, err)
+	}
+}
+
+// Close the Watcher, cancelling pending timers and waiting for its
+// goroutines to exit before closing the Backend. Further calls do
+// nothing.
 func (w *Watcher) Close() error {
-	w.done <- true
-	return w.fsnotify.Close()
+	var err error
+	w.closeOnce.Do(func() {
+		w.spawning.Lock()
+		close(w.done)
+		w.spawning.Unlock()
+		w.cancelHooks()
+		w.stopTimers()
+		w.running.Wait()
+		err = w.backend.Close()
+	})
+	return err
+}
+
+// Run f, converting a panic into an error on the Error channel.
+// Returns true if f returned normally.
+func (w *Watcher) protect(name string, f func()) (ok bool) {
+	defer func() {
+		if r := recover(); r != nil {
+			w.reportError(
+				fmt.Errorf("Recovered from panic in %s: %v", name, r))
+		}
+	}()
+	f()
+	return true
+}
+
+// Run f until it returns normally, restarting it after a panic.
+func (w *Watcher) supervise(name string, f func()) {
+	for !w.protect(name, f) {
+	}
 }
 
 // Find's the best guess for the container package.
 func (w *Watcher) findPackage(file string) (pkg *build.Package) {
 	for file != "." && file != "/" {
-		pkg = w.DirPackages[file]
+		pkg = w.dirs.get(file)
 		if pkg != nil {
 			return pkg
 		}
@@ -134,15 +699,122 @@ func (w *Watcher) findPackage(file string) (pkg *build.Package) {
 	return nil
 }
 
+// The package in exactly the given directory.
+func (w *Watcher) dirPackage(dir string) *build.Package {
+	return w.dirs.get(dir)
+}
+
 // Proxy messages from underlying watcher augmenting it to include the
 // Package the modified file is contained in.
 func (w *Watcher) proxyEvent() {
+	events := w.backend.Events()
 	for {
 		select {
-		case ev := <-w.fsnotify.Event:
-			w.Event <- &Event{FileEvent: ev, Package: w.findPackage(ev.Name)}
+		case ev, ok := <-events:
+			if !ok {
+				w.mu.Lock()
+				w.backendClosed = true
+				w.mu.Unlock()
+				w.reportError(fmt.Errorf("Backend closed its event channel"))
+				events = nil
+				continue
+			}
+			if w.RawEvent != nil {
+				select {
+				case w.RawEvent <- ev:
+				case <-w.done:
+					return
+				}
+			}
+			w.deliver(&Event{FileEvent: ev})
+		case <-w.injector.notify:
+			for ev := w.injector.next(); ev != nil && !w.closed(); ev = w.injector.next() {
+				w.deliver(ev)
+			}
 		case <-w.done:
 			return
 		}
 	}
 }
+
+// Deliver an event to consumers, filling in the Package and Time if
+// necessary.
+func (w *Watcher) deliver(ev *Event) {
+	if ev.FileEvent != nil {
+		if ev.Op == 0 {
+			ev.Op = fileOp(ev.FileEvent)
+		}
+		// copy as the backend event may also be on the RawEvent channel
+		fe := *ev.FileEvent
+		fe.Name = w.names.intern(w.absPath(fe.Name))
+		ev.FileEvent = &fe
+	}
+	w.traceEvent(ev)
+	defer ev.traceDropped()
+	if w.chmodOnly(ev) {
+		return
+	}
+	endAnnotate := ev.traceRegion("annotate")
+	if ev.Package == nil && ev.FileEvent != nil {
+		ev.Package = w.findPackage(ev.Name)
+		if ev.Package == nil && w.toolchainChanged(ev) {
+			endAnnotate()
+			return
+		}
+		if ev.Package != nil {
+			ev.Package = w.filePackage(ev.Package, ev.Name)
+		}
+	}
+	if ev.Time.IsZero() {
+		ev.Time = w.clock.Now()
+	}
+	if ev.Package != nil && ev.FileEvent != nil {
+		ev.ImportPath = ev.Package.ImportPath
+		if path, ok := w.standardImportPath(ev.Name); ok && ev.Package.Goroot {
+			ev.ImportPath, ev.Standard = path, true
+		}
+		if isXTest(ev.Package, ev.Name) {
+			ev.ImportPath, ev.XTest = ev.ImportPath+"_test", true
+		}
+		ev.Module = w.moduleForDir(ev.Package.Dir)
+		if rel, err := filepath.Rel(ev.Package.Dir, ev.Name); err == nil &&
+			rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			ev.RelPath = rel
+		}
+		if ev.AssetDir == "" {
+			ev.AssetDir = w.assetDir(ev.RelPath)
+		}
+		ev.Targets = w.fileTargets(ev.Package, ev.Name)
+	}
+	endAnnotate()
+
+	// keep track of the watched packages even for events not delivered
+	endBookkeeping := ev.traceRegion("bookkeeping")
+	if ev.FileEvent != nil && (ev.IsDelete() || ev.IsRename()) {
+		w.removed(ev.Name)
+		w.rewatchFile(ev)
+	}
+	if ev.Package != nil && ev.FileEvent != nil {
+		if ev.IsCreate() || ev.IsDelete() || ev.IsRename() {
+			w.refreshFiles(ev.Package, ev.Name)
+		}
+		w.refreshGraph(ev.Package, ev.Name)
+		ev.NoImpact = w.noImpact(ev.Package, ev.Name)
+	}
+	w.retryOnChange(ev)
+	endBookkeeping()
+
+	endFilter := ev.traceRegion("filter")
+	drop := w.muted(ev) || w.hiddenFile(ev) || w.filtered(ev) || !w.accept(ev) || !w.runHooks(ev)
+	endFilter()
+	if drop {
+		return
+	}
+	if ev.Package != nil {
+		w.unsettle(ev.Package)
+		if ev.FileEvent != nil {
+			w.diagnose(ev.Package, ev.Name)
+		}
+	}
+	w.emit(ev)
+}
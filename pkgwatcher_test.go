@@ -0,0 +1,197 @@
+package pkgwatcher
+
+import (
+	"github.com/howeyc/fsnotify"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// A Backend recording the watched paths, with events sent by the test.
+type fakeBackend struct {
+	mu      sync.Mutex
+	watched map[string]bool
+	events  chan *fsnotify.FileEvent
+	errors  chan error
+}
+
+func newFakeBackend() *fakeBackend {
+	return &fakeBackend{
+		watched: make(map[string]bool),
+		events:  make(chan *fsnotify.FileEvent),
+		errors:  make(chan error),
+	}
+}
+
+func (b *fakeBackend) Watch(path string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.watched[path] = true
+	return nil
+}
+
+func (b *fakeBackend) RemoveWatch(path string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.watched, path)
+	return nil
+}
+
+func (b *fakeBackend) Events() <-chan *fsnotify.FileEvent { return b.events }
+func (b *fakeBackend) Errors() <-chan error               { return b.errors }
+func (b *fakeBackend) Close() error                       { return nil }
+
+// The watched paths.
+func (b *fakeBackend) paths() []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var paths []string
+	for path := range b.watched {
+		paths = append(paths, path)
+	}
+	return paths
+}
+
+// Create a GOPATH holding the given files, by slash separated name
+// relative to its src directory.
+func tempGopath(t testing.TB, files map[string]string) string {
+	gopath := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(gopath, "src", filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return gopath
+}
+
+// Start a Watcher resolving in the given GOPATH with a fakeBackend and
+// the given options.
+func testWatcher(t testing.TB, gopath, wd string, options ...Option) (*Watcher, *fakeBackend) {
+	b := newFakeBackend()
+	w := newWatcher(wd)
+	w.context.GOPATH = gopath
+	w.mode = GOPATHMode
+	w.backend = b
+	w.errorHandler = func(err error) { t.Error(err) }
+	for _, o := range options {
+		o(w)
+	}
+	w.start()
+	t.Cleanup(func() { w.Close() })
+	return w, b
+}
+
+// Receive the next event or fail after a while.
+func nextEvent(t testing.TB, w *Watcher) *Event {
+	select {
+	case ev := <-w.Event:
+		return ev
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for an event")
+		return nil
+	}
+}
+
+// Check that no event is received for a little while.
+func noEvent(t testing.TB, w *Watcher) {
+	select {
+	case ev := <-w.Event:
+		t.Fatalf("unexpected event %+v", ev)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+// Receive the next PackageEvent or fail after a while.
+func nextPackageEvent(t testing.TB, w *Watcher) *PackageEvent {
+	select {
+	case pe := <-w.PackageEvent:
+		return pe
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for a package event")
+		return nil
+	}
+}
+
+// Check that no PackageEvent is received for a little while.
+func noPackageEvent(t testing.TB, w *Watcher) {
+	select {
+	case pe := <-w.PackageEvent:
+		t.Fatalf("unexpected package event %+v", pe)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+// Wait for a condition to hold or fail after a while.
+func waitFor(t testing.TB, what string, cond func() bool) {
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// Check if the Watcher has a package with the given import path.
+func hasPackage(w *Watcher, importPath string) bool {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return w.Packages[importPath] != nil
+}
+
+func TestEventNamesAreAbsoluteAndClean(t *testing.T) {
+	gopath := tempGopath(t, map[string]string{
+		"p/p.go":     "package p\n",
+		"p/sub/s.go": "package sub\n",
+	})
+	dir := filepath.Join(gopath, "src", "p")
+	w, b := testWatcher(t, gopath, dir)
+	w.WatchImportPath("p", false)
+
+	want := filepath.Join(dir, "p.go")
+	names := append([]string{
+		"p.go",
+		"." + string(filepath.Separator) + "p.go",
+		filepath.Join("sub", "..", "p.go"),
+		want,
+		dir + string(filepath.Separator) + "." + string(filepath.Separator) + "p.go",
+		filepath.Join(dir, "sub") + string(filepath.Separator) + ".." + string(filepath.Separator) + "p.go",
+	}, platformNames(dir)...)
+	for _, name := range names {
+		b.events <- &fsnotify.FileEvent{Name: name}
+		ev := nextEvent(t, w)
+		if ev.Name != want {
+			t.Errorf("event for %q has name %q, want %q", name, ev.Name, want)
+		}
+		if ev.Package == nil || ev.Package.ImportPath != "p" {
+			t.Errorf("event for %q not attributed to package p", name)
+		}
+	}
+}
+
+func TestRelativeWatchesAreAbsolute(t *testing.T) {
+	gopath := tempGopath(t, map[string]string{
+		"p/p.go":       "package p\n",
+		"p/data/d.txt": "",
+	})
+	dir := filepath.Join(gopath, "src", "p")
+	w, b := testWatcher(t, gopath, dir)
+	w.WatchDirectory("data")
+	w.WatchDirectory(filepath.Join(".", "data", "..", "data"))
+
+	paths := b.paths()
+	want := filepath.Join(dir, "data")
+	if len(paths) != 1 || paths[0] != want {
+		t.Fatalf("watched %v, want [%s]", paths, want)
+	}
+	b.events <- &fsnotify.FileEvent{Name: filepath.Join("data", "d.txt")}
+	if ev := nextEvent(t, w); ev.Name != filepath.Join(want, "d.txt") {
+		t.Errorf("got name %q, want %q", ev.Name, filepath.Join(want, "d.txt"))
+	}
+}
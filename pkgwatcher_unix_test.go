@@ -0,0 +1,12 @@
+//go:build !windows
+// +build !windows
+
+package pkgwatcher
+
+// Names backends may report on Unix for dir/p.go.
+func platformNames(dir string) []string {
+	return []string{
+		dir + "//p.go",
+		dir + "/sub//../p.go",
+	}
+}
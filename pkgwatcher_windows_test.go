@@ -0,0 +1,17 @@
+//go:build windows
+// +build windows
+
+package pkgwatcher
+
+import (
+	"path/filepath"
+)
+
+// Names backends may report on Windows for dir\p.go.
+func platformNames(dir string) []string {
+	return []string{
+		filepath.ToSlash(dir) + "/p.go",
+		dir + `\sub/../p.go`,
+		dir + `\\p.go`,
+	}
+}
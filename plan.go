@@ -0,0 +1,78 @@
+package pkgwatcher
+
+import (
+	"sort"
+)
+
+// What watching a set of import paths would involve.
+type Plan struct {
+	Packages    []string // import paths that would be resolved
+	Directories []string // directories that would be monitored, by watch or polling
+	Files       []string // files that would be watched, see SetFileWatches
+	Watches     int      // directories and files that would get file system watches
+	Polled      int      // directories that would be polled, see SetMaxWatches
+	Mode        Mode     // resolution mode that would be used
+	ModFlag     string   // -mod flag in effect in module mode, see DetectModFlag
+}
+
+// Resolve the given import paths with the configuration of the Watcher
+// and report what watching them would involve, without registering any
+// watches or changing what is watched.
+func (w *Watcher) Plan(importPaths []string) Plan {
+	fresh := w.fork()
+	for _, path := range importPaths {
+		fresh.resolve(path, false, RootOptions{})
+	}
+
+	var plan Plan
+	fresh.mu.RLock()
+	plan.Mode = fresh.effectiveMode()
+	fresh.mu.RUnlock()
+	if plan.Mode == ModuleMode {
+		plan.ModFlag = DetectModFlag(fresh.workingDirectory)
+	}
+	w.poller.mu.Lock()
+	max := w.poller.max
+	w.poller.mu.Unlock()
+	allows := func() bool { return max <= 0 || plan.Watches < max }
+
+	// in the order watchPackages uses up the watch budget
+	dirs := make(map[string]bool)
+	files := make(map[string]bool)
+	monitor := func(dir string, watch bool) {
+		if dirs[dir] {
+			return
+		}
+		dirs[dir] = true
+		plan.Directories = append(plan.Directories, dir)
+		if watch {
+			plan.Watches++
+		} else {
+			plan.Polled++
+		}
+	}
+	for _, pkg := range fresh.packagesByDepth() {
+		plan.Packages = append(plan.Packages, pkg.ImportPath)
+		pkgDirs, pkgFiles := fresh.watchedPaths(pkg)
+		for _, dir := range pkgDirs {
+			monitor(dir, allows())
+		}
+		for _, file := range pkgFiles {
+			if files[file] {
+				continue
+			}
+			if !allows() {
+				// the package directory is polled instead
+				monitor(pkg.Dir, false)
+				continue
+			}
+			files[file] = true
+			plan.Files = append(plan.Files, file)
+			plan.Watches++
+		}
+	}
+	sort.Strings(plan.Packages)
+	sort.Strings(plan.Directories)
+	sort.Strings(plan.Files)
+	return plan
+}
@@ -0,0 +1,77 @@
+package pkgwatcher
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"testing"
+)
+
+func TestPlanMatchesWatches(t *testing.T) {
+	files := map[string]string{
+		"p/p.go":          "package p\n\nimport _ \"q\"\n",
+		"p/testdata/in":   "",
+		"p/static/app.js": "",
+		"q/q.go":          "package q\n",
+		"q/data/d.txt":    "",
+	}
+	cases := []struct {
+		name  string
+		setup func(w *Watcher)
+		dirs  []string
+		files []string
+	}{
+		{"directories", func(w *Watcher) {},
+			[]string{"p", "p/static", "q", "q/data"}, nil},
+		{"file watches", func(w *Watcher) { w.SetFileWatches(true) },
+			nil, []string{"p/p.go", "q/q.go"}},
+		{"reduced dependencies", func(w *Watcher) { w.SetReducedDependencies(true) },
+			[]string{"p", "p/static", "q"}, nil},
+		{"root testdata", func(w *Watcher) { w.SetRootTestData(true) },
+			[]string{"p", "p/static", "p/testdata", "q", "q/data"}, nil},
+		{"budget", func(w *Watcher) { w.SetMaxWatches(3) },
+			[]string{"p", "p/static", "q", "q/data"}, nil},
+		{"file budget", func(w *Watcher) { w.SetFileWatches(true); w.SetMaxWatches(1) },
+			[]string{"q"}, []string{"p/p.go"}},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			gopath := tempGopath(t, files)
+			src := filepath.Join(gopath, "src")
+			w, b := testWatcher(t, gopath, filepath.Join(src, "p"))
+			c.setup(w)
+
+			plan := w.Plan([]string{"p"})
+			var dirs, planFiles []string
+			for _, dir := range plan.Directories {
+				rel, _ := filepath.Rel(src, dir)
+				dirs = append(dirs, filepath.ToSlash(rel))
+			}
+			for _, file := range plan.Files {
+				rel, _ := filepath.Rel(src, file)
+				planFiles = append(planFiles, filepath.ToSlash(rel))
+			}
+			if fmt.Sprint(dirs) != fmt.Sprint(c.dirs) || fmt.Sprint(planFiles) != fmt.Sprint(c.files) {
+				t.Fatalf("planned directories %v and files %v, want %v and %v",
+					dirs, planFiles, c.dirs, c.files)
+			}
+			if fmt.Sprint(plan.Packages) != "[p q]" {
+				t.Errorf("planned packages %v, want [p q]", plan.Packages)
+			}
+
+			w.WatchImportPath("p", false)
+			watched, polled := b.paths(), w.poller.list()
+			if plan.Watches != len(watched) || plan.Polled != len(polled) {
+				t.Errorf("planned %d watches and %d polled, got %d and %d",
+					plan.Watches, plan.Polled, len(watched), len(polled))
+			}
+			monitored := append(append([]string(nil), watched...), polled...)
+			want := append(append([]string(nil), plan.Directories...), plan.Files...)
+			sort.Strings(monitored)
+			sort.Strings(want)
+			if fmt.Sprint(monitored) != fmt.Sprint(want) {
+				t.Errorf("monitoring %v, planned %v", monitored, want)
+			}
+		})
+	}
+}
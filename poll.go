@@ -0,0 +1,146 @@
+package pkgwatcher
+
+import (
+	"github.com/howeyc/fsnotify"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// How often directories over the watch budget are polled.
+const DefaultPollInterval = time.Second
+
+// Directories monitored by polling instead of file system watches.
+type poller struct {
+	mu       sync.Mutex
+	interval time.Duration
+	max      int
+	dirs     map[string]map[string]os.FileInfo // dir -> name -> info
+}
+
+// Limit the number of file system watches the Watcher will register. Once
+// the budget is used up, further directories are polled instead. Root
+// packages and their nearest dependencies are watched first. A limit of
+// 0, the default, means no limit. Changes apply to directories watched
+// afterwards.
+func (w *Watcher) SetMaxWatches(n int) {
+	w.poller.mu.Lock()
+	defer w.poller.mu.Unlock()
+	w.poller.max = n
+}
+
+// Set how often directories over the watch budget are polled.
+func (w *Watcher) SetPollInterval(d time.Duration) {
+	w.poller.mu.Lock()
+	defer w.poller.mu.Unlock()
+	w.poller.interval = d
+}
+
+// Check if the watch budget allows for count watches.
+func (p *poller) allows(count int) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.max <= 0 || count < p.max
+}
+
+// Start polling a directory.
+func (p *poller) add(dir string) {
+	files, _ := readDirMap(dir)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.dirs == nil {
+		p.dirs = make(map[string]map[string]os.FileInfo)
+	}
+	p.dirs[dir] = files
+}
+
+// Stop polling a directory, returning false if it was not polled.
+func (p *poller) remove(dir string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	_, ok := p.dirs[dir]
+	delete(p.dirs, dir)
+	return ok
+}
+
+// The polled directories.
+func (p *poller) list() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	dirs := make([]string, 0, len(p.dirs))
+	for dir := range p.dirs {
+		dirs = append(dirs, dir)
+	}
+	return dirs
+}
+
+// Poll the directories over the watch budget until the Watcher is
+// closed, injecting an event for every changed file.
+func (w *Watcher) poll() {
+	for {
+		w.poller.mu.Lock()
+		interval := w.poller.interval
+		w.poller.mu.Unlock()
+		if interval <= 0 {
+			interval = DefaultPollInterval
+		}
+		select {
+		case <-w.clock.After(interval):
+		case <-w.done:
+			return
+		}
+		for _, dir := range w.poller.list() {
+			for _, c := range w.poller.scan(dir) {
+				w.Inject(&Event{FileEvent: &fsnotify.FileEvent{Name: c.name}, Op: c.op})
+			}
+		}
+	}
+}
+
+// A path found changed by polling.
+type polledChange struct {
+	name string
+	op   Op
+}
+
+// Rescan a polled directory returning the paths that changed.
+func (p *poller) scan(dir string) (changed []polledChange) {
+	files, err := readDirMap(dir)
+	if err != nil && !os.IsNotExist(err) {
+		return nil
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	old, ok := p.dirs[dir]
+	if !ok {
+		return nil
+	}
+	for name, info := range files {
+		prev := old[name]
+		if prev == nil {
+			changed = append(changed, polledChange{filepath.Join(dir, name), OpCreate})
+		} else if !prev.ModTime().Equal(info.ModTime()) ||
+			prev.Size() != info.Size() || prev.Mode() != info.Mode() {
+			changed = append(changed, polledChange{filepath.Join(dir, name), OpModify})
+		}
+	}
+	for name := range old {
+		if files[name] == nil {
+			changed = append(changed, polledChange{filepath.Join(dir, name), OpDelete})
+		}
+	}
+	p.dirs[dir] = files
+	return changed
+}
+
+// Read a directory into a map indexed by name.
+func readDirMap(dir string) (map[string]os.FileInfo, error) {
+	infos, err := ioutil.ReadDir(dir)
+	files := make(map[string]os.FileInfo, len(infos))
+	for _, info := range infos {
+		files[info.Name()] = info
+	}
+	return files, err
+}
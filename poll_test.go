@@ -0,0 +1,39 @@
+package pkgwatcher
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPollDirectoriesOverBudget(t *testing.T) {
+	c := newFakeClock()
+	gopath := tempGopath(t, map[string]string{
+		"p/p.go": "package p\n\nimport _ \"q\"\n",
+		"q/q.go": "package q\n",
+	})
+	w, b := testWatcher(t, gopath, filepath.Join(gopath, "src", "p"), WithClock(c))
+	w.SetMaxWatches(1)
+	w.WatchImportPath("p", false)
+	dir := filepath.Join(gopath, "src", "q")
+	if polled := w.poller.list(); len(polled) != 1 || polled[0] != dir {
+		t.Fatalf("polling %v, want [%s]", polled, dir)
+	}
+	if paths := b.paths(); len(paths) != 1 {
+		t.Fatalf("watching %v, want only p", paths)
+	}
+
+	name := filepath.Join(dir, "new.go")
+	if err := os.WriteFile(name, []byte("package q\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	c.blockUntil(t, 2)
+	c.Advance(DefaultPollInterval - 1)
+	noEvent(t, w)
+	c.Advance(1)
+	ev := nextEvent(t, w)
+	if ev.Name != name || ev.Op != OpCreate || ev.Package == nil || ev.Package.ImportPath != "q" {
+		t.Fatalf("got %s %s, want %s %s in q", ev.Op, ev.Name, OpCreate, name)
+	}
+	noEvent(t, w)
+}
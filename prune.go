@@ -0,0 +1,63 @@
+package pkgwatcher
+
+import (
+	"strings"
+)
+
+// Only follow imports under the given import path prefixes during
+// transitive resolution. Roots are always resolved. An empty list, the
+// default, follows all imports. Applies to packages resolved afterwards.
+func (w *Watcher) SetIncludePrefixes(prefixes ...string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.includePrefixes = prefixes
+}
+
+// Do not follow imports under the given import path prefixes during
+// transitive resolution, even if they are included. Applies to packages
+// resolved afterwards.
+func (w *Watcher) SetExcludePrefixes(prefixes ...string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.excludePrefixes = prefixes
+}
+
+// Only follow imports under the given import path prefixes.
+func WithIncludePrefixes(prefixes ...string) Option {
+	return func(w *Watcher) {
+		w.includePrefixes = prefixes
+	}
+}
+
+// Do not follow imports under the given import path prefixes.
+func WithExcludePrefixes(prefixes ...string) Option {
+	return func(w *Watcher) {
+		w.excludePrefixes = prefixes
+	}
+}
+
+// Check if an import should be followed given the prefix lists.
+func (w *Watcher) follows(importPath string) bool {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	if len(w.includePrefixes) > 0 && !hasPathPrefix(importPath, w.includePrefixes) {
+		return false
+	}
+	return !hasPathPrefix(importPath, w.excludePrefixes)
+}
+
+// Check if an import path is under one of the prefixes. A prefix ending
+// in a slash only matches paths below it, otherwise the path itself
+// matches too.
+func hasPathPrefix(importPath string, prefixes []string) bool {
+	for _, p := range prefixes {
+		if strings.HasSuffix(p, "/") {
+			if strings.HasPrefix(importPath, p) {
+				return true
+			}
+		} else if importPath == p || strings.HasPrefix(importPath, p+"/") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,116 @@
+package pkgwatcher
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"github.com/howeyc/fsnotify"
+	"io"
+	"sync"
+	"time"
+)
+
+// Serializes events to a writer so they can be replayed later, one
+// SerializedEvent per line.
+type Recorder struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+// Create a Recorder writing to w.
+func NewRecorder(w io.Writer) *Recorder {
+	return &Recorder{enc: json.NewEncoder(w)}
+}
+
+// Record an event. Events without a file, such as overflow markers, are
+// ignored.
+func (r *Recorder) Record(ev *Event) error {
+	se := Serialize(ev)
+	if se == nil {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.enc.Encode(se)
+}
+
+// Create a Watcher that delivers the events recorded in r instead of
+// watching the file system. The original spacing between events is
+// divided by speed, so 1 replays in real time and 10 ten times faster. A
+// speed of 0 replays as fast as possible. The recording is read before
+// returning; delivery continues in the background until the Watcher is
+// closed.
+func Replay(r io.Reader, speed float64, opts ...Option) (*Watcher, error) {
+	var events []*Event
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 1<<20)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var re SerializedEvent
+		if err := json.Unmarshal(scanner.Bytes(), &re); err != nil {
+			return nil, fmt.Errorf("Invalid recording on line %d: %s", line, err)
+		}
+		if re.Schema > SchemaVersion {
+			return nil, fmt.Errorf(
+				"Unsupported schema version %d on line %d", re.Schema, line)
+		}
+		op, err := ParseOp(re.Op)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid recording on line %d: %s", line, err)
+		}
+		events = append(events, &Event{
+			FileEvent:  &fsnotify.FileEvent{Name: re.Name},
+			Op:         op,
+			ImportPath: re.ImportPath,
+			Time:       re.Time,
+		})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	w, err := New(append([]Option{WithBackend(newNullBackend())}, opts...)...)
+	if err != nil {
+		return nil, err
+	}
+	w.spawn(func() { w.protect("replay", func() { w.replay(events, speed) }) })
+	return w, nil
+}
+
+// Inject the events honoring their original spacing divided by speed.
+func (w *Watcher) replay(events []*Event, speed float64) {
+	for i, ev := range events {
+		if i > 0 && speed > 0 {
+			gap := ev.Time.Sub(events[i-1].Time)
+			if gap > 0 {
+				select {
+				case <-w.clock.After(time.Duration(float64(gap) / speed)):
+				case <-w.done:
+					return
+				}
+			}
+		}
+		w.Inject(ev)
+	}
+}
+
+// A Backend that never produces events.
+type nullBackend struct {
+	events chan *fsnotify.FileEvent
+	errors chan error
+}
+
+func newNullBackend() *nullBackend {
+	return &nullBackend{
+		events: make(chan *fsnotify.FileEvent),
+		errors: make(chan error),
+	}
+}
+
+func (b *nullBackend) Watch(path string) error            { return nil }
+func (b *nullBackend) RemoveWatch(path string) error      { return nil }
+func (b *nullBackend) Events() <-chan *fsnotify.FileEvent { return b.events }
+func (b *nullBackend) Errors() <-chan error               { return b.errors }
+func (b *nullBackend) Close() error                       { return nil }
@@ -0,0 +1,38 @@
+package pkgwatcher
+
+import (
+	"go/build"
+	"path/filepath"
+	"strings"
+)
+
+// Enable or disable reduced fidelity watching of dependencies. When
+// enabled, only the directory of a non-root package is watched, without
+// its subdirectories or asset directories, and only events for its
+// non-test Go files are delivered. Root packages are always watched
+// fully. Applies to packages watched afterwards.
+func (w *Watcher) SetReducedDependencies(enabled bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.reducedDeps = enabled
+}
+
+// Check if a package is watched with reduced fidelity.
+func (w *Watcher) isReduced(pkg *build.Package) bool {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	depth, ok := w.depth[pkg.ImportPath]
+	return w.reducedDeps && ok && depth > 0
+}
+
+// Check if an event is outside what is watched for its package.
+func (w *Watcher) filtered(ev *Event) bool {
+	if ev.FileEvent == nil || ev.Package == nil || ev.Name == ev.Package.Dir {
+		return false
+	}
+	if !w.isReduced(ev.Package) {
+		return false
+	}
+	return filepath.Dir(ev.Name) != ev.Package.Dir ||
+		filepath.Ext(ev.Name) != ".go" || strings.HasSuffix(ev.Name, "_test.go")
+}
@@ -0,0 +1,107 @@
+// Package remotesync mirrors the directories of changed packages to a
+// remote host, for workflows where builds happen remotely.
+package remotesync
+
+import (
+	"fmt"
+	"github.com/daaku/go.pkgwatcher"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// A Syncer copies local directories to their remote location.
+type Syncer interface {
+	Sync(dirs []string) error
+}
+
+// Syncs using the rsync command, mirroring directories below Root to the
+// same relative location below Dest, for example "host:/src". Directories
+// outside Root, such as those of GOPATH or GOROOT dependencies, are
+// skipped.
+type Rsync struct {
+	Root string
+	Dest string
+	Args []string // defaults to -a --delete
+}
+
+// Sync the directories below Root with a single rsync invocation.
+func (r *Rsync) Sync(dirs []string) error {
+	args := r.Args
+	if args == nil {
+		args = []string{"-a", "--delete"}
+	}
+	args = append(append([]string(nil), args...), "--relative")
+	synced := 0
+	for _, dir := range dirs {
+		rel, err := filepath.Rel(r.Root, dir)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			continue
+		}
+		args = append(args, "./"+filepath.ToSlash(rel)+"/")
+		synced++
+	}
+	if synced == 0 {
+		return nil
+	}
+	args = append(args, strings.TrimSuffix(r.Dest, "/")+"/")
+	cmd := exec.Command("rsync", args...)
+	cmd.Dir = r.Root
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("rsync failed with error %s and output:\n%s", err, out)
+	}
+	return nil
+}
+
+// The outcome of syncing a batch of directories.
+type Status struct {
+	Dirs     []string
+	Err      error
+	Time     time.Time
+	Duration time.Duration
+}
+
+// Sync the package directories of the received events until the channel
+// is closed, batching events arriving within the window of each other.
+// The outcome of each batch is sent on status unless it is nil.
+func Run(events <-chan *pkgwatcher.Event, s Syncer, window time.Duration, status chan<- *Status) {
+	dirs := make(map[string]bool)
+	var timer <-chan time.Time
+	for {
+		select {
+		case ev, ok := <-events:
+			if !ok {
+				if len(dirs) > 0 {
+					sync(s, dirs, status)
+				}
+				return
+			}
+			if ev.Package == nil {
+				continue
+			}
+			dirs[ev.Package.Dir] = true
+			timer = time.After(window)
+		case <-timer:
+			sync(s, dirs, status)
+			dirs = make(map[string]bool)
+			timer = nil
+		}
+	}
+}
+
+// Sync a batch of directories reporting the outcome.
+func sync(s Syncer, dirs map[string]bool, status chan<- *Status) {
+	st := &Status{Time: time.Now()}
+	for dir := range dirs {
+		st.Dirs = append(st.Dirs, dir)
+	}
+	sort.Strings(st.Dirs)
+	st.Err = s.Sync(st.Dirs)
+	st.Duration = time.Since(st.Time)
+	if status != nil {
+		status <- st
+	}
+}
@@ -0,0 +1,127 @@
+package pkgwatcher
+
+import (
+	"go/build"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// How long a package whose directory disappeared is kept before it is
+// removed.
+const DefaultRemovalGrace = 2 * time.Second
+
+// Packages whose directories have disappeared.
+type remover struct {
+	mu      sync.Mutex
+	grace   time.Duration
+	pending map[string]Timer // by pkg.Dir
+}
+
+// Set how long a package whose directory disappeared is kept before it
+// is removed, emitting a PackageRemoved event if SetTrackPackages is
+// enabled. If the directory reappears within the grace period, for
+// example in the middle of a git rebase, the package is silently
+// restored.
+func (w *Watcher) SetRemovalGrace(d time.Duration) {
+	w.remover.mu.Lock()
+	defer w.remover.mu.Unlock()
+	w.remover.grace = d
+}
+
+// Handle the removal of a watched path, scheduling the removal of the
+// package if it was a package directory. A main file is indexed by its
+// own name and is watched again as a file instead.
+func (w *Watcher) removed(path string) {
+	pkg := w.dirPackage(path)
+	if pkg == nil || pkg.Dir != path {
+		return
+	}
+	r := &w.remover
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.pending[pkg.Dir] != nil {
+		return
+	}
+	if r.pending == nil {
+		r.pending = make(map[string]Timer)
+	}
+	r.pending[pkg.Dir] = w.afterFunc(r.grace, func() {
+		r.mu.Lock()
+		delete(r.pending, pkg.Dir)
+		r.mu.Unlock()
+		if info, err := os.Stat(pkg.Dir); err == nil && info.IsDir() {
+			w.restorePackage(pkg)
+			return
+		}
+		if current := w.removePackage(pkg.Dir); current != nil {
+			pkg = current
+		}
+		w.mu.RLock()
+		track := w.trackPackages
+		w.mu.RUnlock()
+		if !track {
+			return
+		}
+		w.sendPackageEvent(&PackageEvent{
+			Kind:    PackageRemoved,
+			Package: pkg,
+			Time:    w.clock.Now(),
+		})
+	})
+}
+
+// Forget the directories under dir so they can be watched again.
+func (w *Watcher) unwatchTree(dir string) {
+	w.mu.Lock()
+	var unwatch []string
+	for path := range w.watchedDirectories {
+		if path == dir || strings.HasPrefix(path, dir+string(filepath.Separator)) {
+			delete(w.watchedDirectories, path)
+			if !w.poller.remove(path) {
+				w.watches--
+				unwatch = append(unwatch, path)
+			}
+		}
+	}
+	for path := range w.watchedFiles {
+		if strings.HasPrefix(path, dir+string(filepath.Separator)) {
+			delete(w.watchedFiles, path)
+			w.watches--
+			unwatch = append(unwatch, path)
+		}
+	}
+	w.mu.Unlock()
+	for _, path := range unwatch {
+		w.backend.RemoveWatch(path) // the watch may already be gone
+	}
+}
+
+// Re-establish the watches for a package whose directory reappeared.
+func (w *Watcher) restorePackage(pkg *build.Package) {
+	w.unwatchTree(pkg.Dir)
+	w.watchPackage(pkg)
+}
+
+// Forget the package in a directory that is gone, returning it.
+func (w *Watcher) removePackage(dir string) *build.Package {
+	w.unwatchTree(dir)
+	w.mu.Lock()
+	pkg := w.DirPackages[dir]
+	if pkg == nil {
+		w.mu.Unlock()
+		return nil
+	}
+	delete(w.DirPackages, dir)
+	w.dirs.remove(dir)
+	if p := w.Packages[pkg.ImportPath]; p != nil && p.Dir == dir {
+		delete(w.Packages, pkg.ImportPath)
+		delete(w.depth, pkg.ImportPath)
+		delete(w.expanded, pkg.ImportPath)
+	}
+	w.mu.Unlock()
+	w.forgetPackage(pkg)
+	return pkg
+}
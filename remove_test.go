@@ -0,0 +1,122 @@
+package pkgwatcher
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// Watch package p importing q with package tracking and a fake clock.
+func removalWatcher(t *testing.T) (*Watcher, *fakeBackend, *fakeClock, string) {
+	c := newFakeClock()
+	gopath := tempGopath(t, map[string]string{
+		"p/p.go": "package p\n\nimport _ \"q\"\n",
+		"q/q.go": "package q\n",
+	})
+	w, b := testWatcher(t, gopath, filepath.Join(gopath, "src", "p"), WithClock(c))
+	w.WatchImportPath("p", false)
+	w.SetTrackPackages(true)
+	return w, b, c, filepath.Join(gopath, "src", "q")
+}
+
+// Remove a package directory, delivering the event for it.
+func removeDir(t *testing.T, w *Watcher, dir string) {
+	if err := os.RemoveAll(dir); err != nil {
+		t.Fatal(err)
+	}
+	injectFile(w, dir, OpDelete)
+	// the removal is scheduled before the event is delivered
+	nextEvent(t, w)
+}
+
+func TestRemovalAfterGracePeriod(t *testing.T) {
+	w, _, c, dir := removalWatcher(t)
+	removeDir(t, w, dir)
+	c.Advance(DefaultRemovalGrace - 1)
+	noPackageEvent(t, w)
+	if !hasPackage(w, "q") {
+		t.Fatal("q removed before the grace period ended")
+	}
+	c.Advance(1)
+	pe := nextPackageEvent(t, w)
+	if pe.Kind != PackageRemoved || pe.Package.ImportPath != "q" {
+		t.Fatalf("got %s for %s, want PackageRemoved for q", pe.Kind, pe.Package.ImportPath)
+	}
+	if hasPackage(w, "q") {
+		t.Fatal("q still watched after removal")
+	}
+}
+
+func TestRemovalRestoredWithinGracePeriod(t *testing.T) {
+	w, b, c, dir := removalWatcher(t)
+	removeDir(t, w, dir)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	c.Advance(DefaultRemovalGrace)
+	noPackageEvent(t, w)
+	if !hasPackage(w, "q") {
+		t.Fatal("q removed although its directory reappeared")
+	}
+	waitFor(t, "q to be watched again", func() bool {
+		for _, path := range b.paths() {
+			if path == dir {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+func TestRemovalNotTrackedIsSilent(t *testing.T) {
+	w, _, c, dir := removalWatcher(t)
+	w.SetTrackPackages(false)
+	removeDir(t, w, dir)
+	c.Advance(DefaultRemovalGrace)
+	waitFor(t, "q to be removed", func() bool { return !hasPackage(w, "q") })
+	noPackageEvent(t, w)
+}
+
+func TestMainFileRenameKeepsWatches(t *testing.T) {
+	c := newFakeClock()
+	gopath := tempGopath(t, map[string]string{
+		"app/main.go":    "package main\n\nimport _ \"app/sub\"\n",
+		"app/sub/sub.go": "package sub\n",
+	})
+	dir := filepath.Join(gopath, "src", "app")
+	w, b := testWatcher(t, gopath, dir, WithClock(c))
+	if err := w.WatchMainFile("main.go"); err != nil {
+		t.Fatal(err)
+	}
+	sub := filepath.Join(dir, "sub")
+	watched := func() bool {
+		for _, path := range b.paths() {
+			if path == sub {
+				return true
+			}
+		}
+		return false
+	}
+	if !watched() {
+		t.Fatalf("watching %v, want %s", b.paths(), sub)
+	}
+
+	// an atomic save replaces the file by renaming over it
+	injectFile(w, filepath.Join(dir, "main.go"), OpRename)
+	nextEvent(t, w)
+	w.remover.mu.Lock()
+	pending := len(w.remover.pending)
+	w.remover.mu.Unlock()
+	if pending != 0 {
+		t.Fatal("removal scheduled for the main file")
+	}
+	c.Advance(DefaultRemovalGrace)
+	time.Sleep(50 * time.Millisecond)
+	if !watched() {
+		t.Fatalf("watching %v after the save, want %s", b.paths(), sub)
+	}
+	if !hasPackage(w, MainImportPath) || !hasPackage(w, "app/sub") {
+		t.Fatal("packages removed by the save")
+	}
+}
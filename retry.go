@@ -0,0 +1,116 @@
+package pkgwatcher
+
+import (
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// How often imports that failed to resolve are retried.
+const DefaultRetryInterval = 30 * time.Second
+
+// Imports that failed to resolve, such as dependencies not downloaded
+// yet in a fresh clone.
+type retrier struct {
+	mu       sync.Mutex
+	interval time.Duration
+	failed   map[string]string // import path -> root it was reached from
+	kick     chan bool
+}
+
+// Set how often imports that failed to resolve are retried. Retries also
+// happen soon after a go.mod or go.sum file changes, as when go get or go
+// mod download complete. An interval of 0 disables periodic retries.
+func (w *Watcher) SetRetryInterval(d time.Duration) {
+	w.retrier.mu.Lock()
+	defer w.retrier.mu.Unlock()
+	w.retrier.interval = d
+}
+
+// Record an import that failed to resolve, returning true if it was
+// already known to fail.
+func (r *retrier) add(importPath, root string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.failed[importPath]; ok {
+		return true
+	}
+	if r.failed == nil {
+		r.failed = make(map[string]string)
+	}
+	r.failed[importPath] = root
+	return false
+}
+
+// Retry soon after a change to a go.mod or go.sum file.
+func (w *Watcher) retryOnChange(ev *Event) {
+	if ev.FileEvent == nil {
+		return
+	}
+	if name := filepath.Base(ev.Name); name != "go.mod" && name != "go.sum" {
+		return
+	}
+	select {
+	case w.retrier.kick <- true:
+	default:
+	}
+}
+
+// Retry failed imports periodically or when kicked until the Watcher is
+// closed.
+func (w *Watcher) retry() {
+	for {
+		w.retrier.mu.Lock()
+		interval := w.retrier.interval
+		w.retrier.mu.Unlock()
+		var tick <-chan time.Time
+		if interval > 0 {
+			tick = w.clock.After(interval)
+		}
+		select {
+		case <-tick:
+		case <-w.retrier.kick:
+			// let the go command finish writing
+			select {
+			case <-w.clock.After(time.Second):
+			case <-w.done:
+				return
+			}
+		case <-w.done:
+			return
+		}
+		w.retryResolution()
+	}
+}
+
+// Resolve the roots of failed imports again, watching what is found.
+// Imports failing again are not reported again.
+func (w *Watcher) retryResolution() {
+	r := &w.retrier
+	r.mu.Lock()
+	roots := make(map[string]bool)
+	for _, root := range r.failed {
+		roots[root] = true
+	}
+	r.mu.Unlock()
+	if len(roots) == 0 {
+		return
+	}
+	for root := range roots {
+		w.mu.RLock()
+		opts := w.roots[root]
+		w.mu.RUnlock()
+		w.resolve(root, true, opts)
+	}
+	w.watchPackages()
+
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for importPath := range r.failed {
+		if w.Packages[importPath] != nil {
+			delete(r.failed, importPath)
+		}
+	}
+}
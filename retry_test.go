@@ -0,0 +1,61 @@
+package pkgwatcher
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// Watch package p importing the missing package q, returning the
+// directory to create q in.
+func retryWatcher(t *testing.T) (*Watcher, *fakeClock, string) {
+	c := newFakeClock()
+	gopath := tempGopath(t, map[string]string{
+		"p/p.go": "package p\n\nimport _ \"q\"\n",
+	})
+	w, _ := testWatcher(t, gopath, filepath.Join(gopath, "src", "p"), WithClock(c))
+	errs := make(chan error, 10)
+	w.SetErrorHandler(func(err error) { errs <- err })
+	w.WatchImportPath("p", false)
+	if len(errs) != 1 || hasPackage(w, "q") {
+		t.Fatalf("got %d errors resolving the missing package, want 1", len(errs))
+	}
+	return w, c, filepath.Join(gopath, "src", "q")
+}
+
+// Create the package q in the given directory.
+func createQ(t *testing.T, dir string) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "q.go"), []byte("package q\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRetryPeriodically(t *testing.T) {
+	w, c, dir := retryWatcher(t)
+	createQ(t, dir)
+	// the poller and the retrier
+	c.blockUntil(t, 2)
+	c.Advance(DefaultRetryInterval - 1)
+	noEvent(t, w)
+	if hasPackage(w, "q") {
+		t.Fatal("q resolved before the retry interval")
+	}
+	c.Advance(1)
+	waitFor(t, "q to be resolved", func() bool { return hasPackage(w, "q") })
+}
+
+func TestRetryAfterModuleChange(t *testing.T) {
+	w, c, dir := retryWatcher(t)
+	createQ(t, dir)
+	c.blockUntil(t, 2)
+	injectFile(w, filepath.Join(filepath.Dir(dir), "p", "go.mod"), OpModify)
+	nextEvent(t, w)
+	// the retrier now also waits for the go command to finish
+	c.blockUntil(t, 3)
+	c.Advance(time.Second)
+	waitFor(t, "q to be resolved", func() bool { return hasPackage(w, "q") })
+}
@@ -0,0 +1,95 @@
+package pkgwatcher
+
+import (
+	"fmt"
+	"sync"
+)
+
+// Run action for each of the given import paths, with at most
+// concurrency actions running at once. An action only starts after the
+// actions for all the given packages it transitively depends on have
+// succeeded; actions depending on a failed one are skipped. Returns the
+// first error. Concurrency below 1 is treated as 1.
+func (w *Watcher) Schedule(importPaths []string, concurrency int, action func(importPath string) error) error {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	deps := w.scheduleDeps(importPaths)
+
+	type task struct {
+		done chan bool
+		err  error
+	}
+	tasks := make(map[string]*task, len(importPaths))
+	for _, path := range importPaths {
+		tasks[path] = &task{done: make(chan bool)}
+	}
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		firstErr error
+	)
+	slots := make(chan bool, concurrency)
+	for path, t := range tasks {
+		wg.Add(1)
+		go func(path string, t *task) {
+			defer wg.Done()
+			defer close(t.done)
+			for _, dep := range deps[path] {
+				d := tasks[dep]
+				<-d.done
+				if d.err != nil {
+					t.err = fmt.Errorf("Skipped %s as %s failed", path, dep)
+					return
+				}
+			}
+			slots <- true
+			t.err = action(path)
+			<-slots
+			if t.err != nil {
+				mu.Lock()
+				if firstErr == nil {
+					firstErr = t.err
+				}
+				mu.Unlock()
+			}
+		}(path, t)
+	}
+	wg.Wait()
+	return firstErr
+}
+
+// For each of the given import paths, the others it transitively
+// depends on through the resolved packages.
+func (w *Watcher) scheduleDeps(importPaths []string) map[string][]string {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	wanted := make(map[string]bool, len(importPaths))
+	for _, path := range importPaths {
+		wanted[path] = true
+	}
+	deps := make(map[string][]string, len(importPaths))
+	for _, root := range importPaths {
+		seen := map[string]bool{root: true}
+		stack := []string{root}
+		for len(stack) > 0 {
+			path := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			pkg := w.Packages[path]
+			if pkg == nil {
+				continue
+			}
+			for _, imp := range pkg.Imports {
+				if seen[imp] {
+					continue
+				}
+				seen[imp] = true
+				if wanted[imp] {
+					deps[root] = append(deps[root], imp)
+				}
+				stack = append(stack, imp)
+			}
+		}
+	}
+	return deps
+}
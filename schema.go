@@ -0,0 +1,46 @@
+package pkgwatcher
+
+import (
+	"time"
+)
+
+// The version of the SerializedEvent schema. Within a version, fields
+// are only ever added, so consumers must ignore fields they do not know.
+// Removing, renaming or changing the meaning of a field bumps the
+// version.
+const SchemaVersion = 1
+
+// The stable JSON form of an Event, used by the Recorder and hooks.
+type SerializedEvent struct {
+	Schema     int       `json:"schema"`
+	Time       time.Time `json:"time"`
+	Name       string    `json:"name"`
+	Op         string    `json:"op"`
+	ImportPath string    `json:"importPath,omitempty"`
+	RelPath    string    `json:"relPath,omitempty"`
+	Module     string    `json:"module,omitempty"`
+	Standard   bool      `json:"standard,omitempty"`
+	NoImpact   bool      `json:"noImpact,omitempty"`
+}
+
+// Convert an event to its serialized form. Returns nil for events
+// without a file, such as overflow markers.
+func Serialize(ev *Event) *SerializedEvent {
+	if ev.FileEvent == nil {
+		return nil
+	}
+	se := &SerializedEvent{
+		Schema:     SchemaVersion,
+		Time:       ev.Time,
+		Name:       ev.Name,
+		Op:         ev.Op.String(),
+		ImportPath: ev.ImportPath,
+		RelPath:    ev.RelPath,
+		Standard:   ev.Standard,
+		NoImpact:   ev.NoImpact,
+	}
+	if ev.Module != nil {
+		se.Module = ev.Module.Path
+	}
+	return se
+}
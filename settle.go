@@ -0,0 +1,53 @@
+package pkgwatcher
+
+import (
+	"go/build"
+	"sync"
+	"time"
+)
+
+// Tracks bursts of changes per package to emit PackageSettled events.
+type settler struct {
+	mu     sync.Mutex
+	quiet  time.Duration
+	timers map[string]Timer // by pkg.Dir
+}
+
+// Set the quiet period after which a PackageSettled event is emitted
+// for a package that has stopped changing. A period of 0, the default,
+// disables PackageSettled events.
+func (w *Watcher) SetQuietPeriod(d time.Duration) {
+	w.settler.mu.Lock()
+	defer w.settler.mu.Unlock()
+	w.settler.quiet = d
+}
+
+// Note a change in the given package, restarting its quiet period.
+func (w *Watcher) unsettle(pkg *build.Package) {
+	s := &w.settler
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.quiet <= 0 {
+		return
+	}
+	if t := s.timers[pkg.Dir]; t != nil {
+		t.Reset(s.quiet)
+		return
+	}
+	if s.timers == nil {
+		s.timers = make(map[string]Timer)
+	}
+	s.timers[pkg.Dir] = w.afterFunc(s.quiet, func() {
+		s.mu.Lock()
+		delete(s.timers, pkg.Dir)
+		s.mu.Unlock()
+		if current := w.dirPackage(pkg.Dir); current != nil {
+			pkg = current
+		}
+		w.sendPackageEvent(&PackageEvent{
+			Kind:    PackageSettled,
+			Package: pkg,
+			Time:    w.clock.Now(),
+		})
+	})
+}
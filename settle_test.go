@@ -0,0 +1,35 @@
+package pkgwatcher
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestQuietPeriodSettlesPackage(t *testing.T) {
+	c := newFakeClock()
+	gopath := tempGopath(t, map[string]string{"p/p.go": "package p\n"})
+	dir := filepath.Join(gopath, "src", "p")
+	w, _ := testWatcher(t, gopath, dir, WithClock(c))
+	w.SetQuietPeriod(100 * time.Millisecond)
+	w.WatchImportPath("p", false)
+	name := filepath.Join(dir, "p.go")
+
+	// the quiet period is restarted before the event is delivered
+	injectFile(w, name, OpModify)
+	nextEvent(t, w)
+	c.Advance(60 * time.Millisecond)
+	injectFile(w, name, OpModify)
+	nextEvent(t, w)
+	c.Advance(60 * time.Millisecond)
+	noPackageEvent(t, w)
+	c.Advance(40 * time.Millisecond)
+	pe := nextPackageEvent(t, w)
+	if pe.Kind != PackageSettled || pe.Package.ImportPath != "p" {
+		t.Fatalf("got %s for %s, want PackageSettled for p", pe.Kind, pe.Package.ImportPath)
+	}
+	if !pe.Time.Equal(c.Now()) {
+		t.Errorf("settled at %s, want %s", pe.Time, c.Now())
+	}
+	noPackageEvent(t, w)
+}
@@ -0,0 +1,64 @@
+package pkgwatcher
+
+import (
+	"go/build"
+)
+
+// Reasons for PackageSkipped events.
+const (
+	SkipBinaryOnly  = "binary-only package"
+	SkipModuleCache = "read-only module cache"
+	SkipGoroot      = "GOROOT is not watched"
+)
+
+// Enable or disable watching packages in GOROOT. They are watched by
+// default. Applies to packages watched afterwards.
+func (w *Watcher) SetWatchGoroot(enabled bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.skipGoroot = !enabled
+}
+
+// Do not watch packages in GOROOT.
+func WithoutGoroot() Option {
+	return func(w *Watcher) {
+		w.skipGoroot = true
+	}
+}
+
+// The reason a resolved package is not watched, if any: binary-only
+// packages have no sources, the module cache never changes, and GOROOT
+// may be excluded. With SetTrackPackages a PackageSkipped event is
+// emitted for such packages.
+func (w *Watcher) skipReason(pkg *build.Package) string {
+	w.mu.RLock()
+	skipGoroot := w.skipGoroot
+	w.mu.RUnlock()
+	var reason string
+	switch {
+	case pkg.BinaryOnly:
+		reason = SkipBinaryOnly
+	case pkg.Goroot && skipGoroot:
+		reason = SkipGoroot
+	case pkg.ImportPath != MainImportPath:
+		if mod := w.moduleForDir(pkg.Dir); mod != nil && mod.Version != "" {
+			reason = SkipModuleCache
+		}
+	}
+	return reason
+}
+
+// Report a package that is not watched.
+func (w *Watcher) skipped(pkg *build.Package, reason string) {
+	w.mu.RLock()
+	track := w.trackPackages
+	w.mu.RUnlock()
+	if track {
+		w.sendPackageEvent(&PackageEvent{
+			Kind:    PackageSkipped,
+			Package: pkg,
+			Reason:  reason,
+			Time:    w.clock.Now(),
+		})
+	}
+}
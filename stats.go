@@ -0,0 +1,66 @@
+package pkgwatcher
+
+import (
+	"go/build"
+	"sort"
+)
+
+// Statistics about what a Watcher is monitoring.
+type Stats struct {
+	Packages    int            // number of resolved packages
+	Directories int            // number of monitored directories
+	Watches     int            // directories monitored with file system watches
+	Polled      []string       // import paths of packages with polled directories
+	Mode        Mode           // resolution mode in effect
+	ModFlag     string         // -mod flag in effect in module mode, see DetectModFlag
+	Muted       map[string]int // events dropped per muted import path
+	Memory      int            // rough estimate of the bytes held
+
+	// Kernel watches held by the process and the per user limit, -1 if
+	// unknown. Only available for inotify on linux. When events stop
+	// arriving, compare them.
+	KernelWatches    int
+	KernelMaxWatches int
+}
+
+// Get the current Stats.
+func (w *Watcher) Stats() Stats {
+	w.mu.RLock()
+	s := Stats{
+		Packages:    len(w.Packages),
+		Directories: len(w.watchedDirectories),
+		Watches:     w.watches,
+		Mode:        w.effectiveMode(),
+	}
+	w.mu.RUnlock()
+	if s.Mode == ModuleMode {
+		s.ModFlag = DetectModFlag(w.workingDirectory)
+	}
+	s.Muted = w.mutedCounts()
+	s.Memory = w.memoryEstimate()
+	s.KernelWatches, s.KernelMaxWatches = kernelWatches()
+
+	polled := make(map[string]bool)
+	for _, dir := range w.poller.list() {
+		if pkg := w.findPackage(dir); pkg != nil {
+			polled[pkg.ImportPath] = true
+		}
+	}
+	for path := range polled {
+		s.Polled = append(s.Polled, path)
+	}
+	sort.Strings(s.Polled)
+	return s
+}
+
+// A consistent snapshot of the resolved packages sorted by import path.
+func (w *Watcher) PackageList() []*build.Package {
+	w.mu.RLock()
+	pkgs := make([]*build.Package, 0, len(w.Packages))
+	for _, pkg := range w.Packages {
+		pkgs = append(pkgs, pkg)
+	}
+	w.mu.RUnlock()
+	sort.Slice(pkgs, func(i, j int) bool { return pkgs[i].ImportPath < pkgs[j].ImportPath })
+	return pkgs
+}
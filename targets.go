@@ -0,0 +1,63 @@
+package pkgwatcher
+
+import (
+	"go/build"
+	"path/filepath"
+)
+
+// An additional build target resolved alongside the main build context.
+type target struct {
+	name     string
+	context  build.Context
+	packages map[string]bool // import paths resolved for the target
+}
+
+// Register an additional build target, such as darwin/arm64 alongside
+// the host. Roots are resolved for every target and the union of their
+// dependencies is watched, and Event.Targets lists the targets the
+// changed file is part of. Targets are ignored when a Resolver is set.
+// Applies to packages resolved afterwards.
+func (w *Watcher) AddTarget(name string, ctxt build.Context) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for _, t := range w.targets {
+		if t.name == name {
+			t.context = ctxt
+			return
+		}
+	}
+	w.targets = append(w.targets, &target{
+		name:     name,
+		context:  ctxt,
+		packages: make(map[string]bool),
+	})
+}
+
+// Register an additional build target.
+func WithTarget(name string, ctxt build.Context) Option {
+	return func(w *Watcher) {
+		w.AddTarget(name, ctxt)
+	}
+}
+
+// The names of the targets a file in a package is part of. Go files are
+// matched against the build constraints of each target, other files are
+// part of every target the package is.
+func (w *Watcher) fileTargets(pkg *build.Package, file string) []string {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	var names []string
+	for _, t := range w.targets {
+		if !t.packages[pkg.ImportPath] {
+			continue
+		}
+		if filepath.Ext(file) == ".go" {
+			ctxt := t.context
+			if ok, err := ctxt.MatchFile(filepath.Dir(file), filepath.Base(file)); err != nil || !ok {
+				continue
+			}
+		}
+		names = append(names, t.name)
+	}
+	return names
+}
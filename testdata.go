@@ -0,0 +1,38 @@
+package pkgwatcher
+
+import (
+	"go/build"
+	"os"
+	"path/filepath"
+)
+
+// Enable or disable watching the testdata directories of root packages.
+// Test fixtures are inputs to the tests of the packages being worked on,
+// so events in them are attributed to the package, while testdata of
+// dependencies stays excluded. Applies to packages watched afterwards.
+func (w *Watcher) SetRootTestData(enabled bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.rootTestData = enabled
+}
+
+// Watch the testdata directories of root packages.
+func WithRootTestData() Option {
+	return func(w *Watcher) {
+		w.rootTestData = true
+	}
+}
+
+// Watch the testdata directory of a root package if enabled.
+func (w *Watcher) watchTestData(pkg *build.Package) {
+	w.mu.RLock()
+	enabled := w.rootTestData
+	w.mu.RUnlock()
+	if !enabled || !w.isRoot(pkg.ImportPath) {
+		return
+	}
+	dir := filepath.Join(pkg.Dir, "testdata")
+	if info, err := os.Stat(dir); err == nil && info.IsDir() {
+		w.WatchDirectory(dir)
+	}
+}
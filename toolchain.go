@@ -0,0 +1,78 @@
+package pkgwatcher
+
+import (
+	"fmt"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// How long toolchain changes must stop before ToolchainChanged is
+// emitted, as an upgrade touches the watched files more than once.
+const toolchainQuiet = time.Second
+
+// Watches the go binary and VERSION file of GOROOT.
+type toolchain struct {
+	mu      sync.Mutex
+	enabled bool
+	files   map[string]bool
+	timer   Timer
+}
+
+// Watch the go binary and the VERSION file in GOROOT, emitting a
+// ToolchainChanged event when they change, after which all build caches
+// should be considered invalid.
+func WithToolchainWatch() Option {
+	return func(w *Watcher) {
+		w.toolchain.enabled = true
+	}
+}
+
+// Start watching the toolchain if enabled.
+func (w *Watcher) watchToolchain() {
+	t := &w.toolchain
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if !t.enabled {
+		return
+	}
+	w.mu.RLock()
+	goroot := w.context.GOROOT
+	w.mu.RUnlock()
+	bin := filepath.Join(goroot, "bin")
+	t.files = map[string]bool{
+		filepath.Join(goroot, "VERSION"): true,
+		filepath.Join(bin, "go"):         true,
+		filepath.Join(bin, "go.exe"):     true,
+	}
+	for _, dir := range []string{goroot, bin} {
+		if err := w.backend.Watch(dir); err != nil {
+			w.reportError(fmt.Errorf("Error watching toolchain %s: %s", dir, err))
+		}
+	}
+}
+
+// Check if an event is for the toolchain, scheduling a ToolchainChanged
+// event if so.
+func (w *Watcher) toolchainChanged(ev *Event) bool {
+	t := &w.toolchain
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if ev.FileEvent == nil || !t.files[ev.Name] {
+		return false
+	}
+	if t.timer != nil {
+		t.timer.Reset(toolchainQuiet)
+		return true
+	}
+	t.timer = w.afterFunc(toolchainQuiet, func() {
+		t.mu.Lock()
+		t.timer = nil
+		t.mu.Unlock()
+		w.sendPackageEvent(&PackageEvent{
+			Kind: ToolchainChanged,
+			Time: w.clock.Now(),
+		})
+	})
+	return true
+}
@@ -0,0 +1,185 @@
+package pkgwatcher
+
+import (
+	"context"
+	"runtime/trace"
+	"sync"
+)
+
+// Receives spans for the stages of the pipeline, for example to export
+// them with the OpenTelemetry SDK. Start begins a span named name as a
+// child of any span in ctx, returning the context carrying it and a
+// function ending it. The outcome of an event span is "sent", "dropped"
+// or "superseded", and empty for other spans.
+type Instrumentation interface {
+	Start(ctx context.Context, name string) (context.Context, func(outcome string))
+}
+
+// The runtime/trace task and Instrumentation span following an event
+// through the Watcher.
+type eventTrace struct {
+	ctx     context.Context
+	task    *trace.Task // nil unless tracing
+	span    func(outcome string)
+	instr   Instrumentation
+	queued  bool // handed to the debouncer
+	endOnce sync.Once
+}
+
+// Annotate the pipeline with runtime/trace tasks and regions. Every event
+// gets a "pkgwatcher.event" task with regions for annotating, bookkeeping,
+// filtering and sending on the Event channel, and a log entry when it waits
+// for the debounce delay. Every WatchRoot gets a "pkgwatcher.resolve"
+// task. Use Event.TraceContext to add regions for actions run in response
+// to an event. The annotations are only recorded while trace.Start is
+// active.
+func (w *Watcher) SetTracing(enabled bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.tracing = enabled
+}
+
+// Annotate the pipeline with runtime/trace tasks and regions.
+func WithTracing() Option {
+	return func(w *Watcher) {
+		w.tracing = true
+	}
+}
+
+// Report the stages of the pipeline to the given Instrumentation: a
+// "pkgwatcher.event" span for every event with child spans for its
+// stages, and a "pkgwatcher.resolve" span for every WatchRoot. Pass nil
+// to disable it.
+func (w *Watcher) SetInstrumentation(i Instrumentation) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.instrumentation = i
+}
+
+// Report the stages of the pipeline to the given Instrumentation.
+func WithInstrumentation(i Instrumentation) Option {
+	return func(w *Watcher) {
+		w.instrumentation = i
+	}
+}
+
+// Check if tracing is enabled, and get the Instrumentation if any.
+func (w *Watcher) traces() (bool, Instrumentation) {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return w.tracing, w.instrumentation
+}
+
+// The context of the trace task and Instrumentation span for the event,
+// or the background context if both are disabled. Regions, logs and
+// spans created with it are grouped with the event.
+func (ev *Event) TraceContext() context.Context {
+	if ev.trace == nil {
+		return context.Background()
+	}
+	return ev.trace.ctx
+}
+
+// Start the trace task and Instrumentation span for an event.
+func (w *Watcher) traceEvent(ev *Event) {
+	if ev.trace != nil {
+		return
+	}
+	tracing, instr := w.traces()
+	if !tracing && instr == nil {
+		return
+	}
+	t := &eventTrace{ctx: context.Background(), instr: instr}
+	if instr != nil {
+		t.ctx, t.span = instr.Start(t.ctx, "pkgwatcher.event")
+	}
+	if tracing {
+		t.ctx, t.task = trace.NewTask(t.ctx, "pkgwatcher.event")
+		if ev.FileEvent != nil {
+			trace.Log(t.ctx, "file", ev.Name)
+		}
+	}
+	ev.trace = t
+}
+
+// Start a region in the trace task for an event, returning the function
+// ending it.
+func (ev *Event) traceRegion(name string) func() {
+	if ev.trace == nil {
+		return func() {}
+	}
+	var region *trace.Region
+	if ev.trace.task != nil {
+		region = trace.StartRegion(ev.trace.ctx, name)
+	}
+	var span func(string)
+	if ev.trace.instr != nil {
+		_, span = ev.trace.instr.Start(ev.trace.ctx, name)
+	}
+	return func() {
+		if span != nil {
+			span("")
+		}
+		if region != nil {
+			region.End()
+		}
+	}
+}
+
+// Record that an event is waiting for the debounce delay.
+func (ev *Event) traceQueued() {
+	if ev.trace == nil {
+		return
+	}
+	ev.trace.queued = true
+	if ev.trace.task != nil {
+		trace.Log(ev.trace.ctx, "debounce", "queued")
+	}
+}
+
+// End the trace of an event that was not handed to the debouncer.
+func (ev *Event) traceDropped() {
+	if ev.trace != nil && !ev.trace.queued {
+		ev.traceEnd("dropped")
+	}
+}
+
+// End the trace of an event with the given outcome.
+func (ev *Event) traceEnd(outcome string) {
+	if ev.trace == nil {
+		return
+	}
+	ev.trace.endOnce.Do(func() {
+		if ev.trace.task != nil {
+			trace.Log(ev.trace.ctx, "outcome", outcome)
+			ev.trace.task.End()
+		}
+		if ev.trace.span != nil {
+			ev.trace.span(outcome)
+		}
+	})
+}
+
+// Start the trace task and Instrumentation span for resolving a root,
+// returning the function ending them.
+func (w *Watcher) traceResolve(importPath string) func() {
+	tracing, instr := w.traces()
+	ctx := context.Background()
+	var span func(string)
+	if instr != nil {
+		ctx, span = instr.Start(ctx, "pkgwatcher.resolve")
+	}
+	var task *trace.Task
+	if tracing {
+		ctx, task = trace.NewTask(ctx, "pkgwatcher.resolve")
+		trace.Log(ctx, "import path", importPath)
+	}
+	return func() {
+		if task != nil {
+			task.End()
+		}
+		if span != nil {
+			span("")
+		}
+	}
+}
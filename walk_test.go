@@ -0,0 +1,115 @@
+package pkgwatcher
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Create a tree of directories below a temporary directory with the
+// given fan out at each level, returning its root.
+func makeTree(tb testing.TB, fanout ...int) string {
+	root := tb.TempDir()
+	dirs := []string{root}
+	for _, n := range fanout {
+		var next []string
+		for _, dir := range dirs {
+			for i := 0; i < n; i++ {
+				next = append(next, filepath.Join(dir, fmt.Sprintf("d%d", i)))
+			}
+		}
+		dirs = next
+	}
+	for _, dir := range dirs {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			tb.Fatal(err)
+		}
+	}
+	return root
+}
+
+// A Backend whose watches take a while, tracking how many are added at
+// once.
+type slowBackend struct {
+	fakeBackend
+	mu          sync.Mutex
+	inflight    int
+	maxInflight int
+}
+
+func (b *slowBackend) Watch(path string) error {
+	b.mu.Lock()
+	b.inflight++
+	if b.inflight > b.maxInflight {
+		b.maxInflight = b.inflight
+	}
+	b.mu.Unlock()
+	time.Sleep(time.Millisecond)
+	b.mu.Lock()
+	b.inflight--
+	b.mu.Unlock()
+	return b.fakeBackend.Watch(path)
+}
+
+// Create an unstarted Watcher using the given Backend.
+func walkWatcher(tb testing.TB, b Backend) *Watcher {
+	w := newWatcher(tb.TempDir())
+	w.backend = b
+	w.errorHandler = func(err error) { tb.Error(err) }
+	return w
+}
+
+func TestWatchDirectoryMatchesSerialWalk(t *testing.T) {
+	root := makeTree(t, 6, 4, 3)
+	for _, skip := range []string{"vendor", "testdata", ".git"} {
+		if err := os.MkdirAll(filepath.Join(root, "d1", skip, "x"), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	concurrent := newFakeBackend()
+	walkWatcher(t, concurrent).WatchDirectory(root)
+	serial := newFakeBackend()
+	sw := walkWatcher(t, serial)
+	sw.walk(root, root)
+
+	got, want := concurrent.paths(), serial.paths()
+	sort.Strings(got)
+	sort.Strings(want)
+	if len(got) != 1+6+6*4+6*4*3 || fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Fatalf("concurrent walk watched %d directories, serial walk %d",
+			len(got), len(want))
+	}
+}
+
+func TestWatchDirectoryWalksConcurrently(t *testing.T) {
+	root := makeTree(t, maxWalkers*2, 4)
+	b := &slowBackend{fakeBackend: *newFakeBackend()}
+	walkWatcher(t, b).WatchDirectory(root)
+	if b.maxInflight < 2 {
+		t.Fatalf("at most %d watches were added at once", b.maxInflight)
+	}
+	if b.maxInflight > maxWalkers+1 {
+		t.Fatalf("%d watches were added at once, more than %d walkers",
+			b.maxInflight, maxWalkers)
+	}
+}
+
+// Watch a tree of 20000 directories, comparing with the serial walk.
+func BenchmarkWatchDirectory(b *testing.B) {
+	root := makeTree(b, 20, 25, 40)
+	b.Run("concurrent", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			walkWatcher(b, newFakeBackend()).WatchDirectory(root)
+		}
+	})
+	b.Run("serial", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			walkWatcher(b, newFakeBackend()).walk(root, root)
+		}
+	})
+}
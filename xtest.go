@@ -0,0 +1,40 @@
+package pkgwatcher
+
+import (
+	"go/build"
+	"go/parser"
+	"go/token"
+	"path/filepath"
+	"strings"
+)
+
+// Check if a file in a package directory belongs to the external test
+// package, using the file sets of the package and falling back to the
+// package clause of files it does not know yet.
+func isXTest(pkg *build.Package, file string) bool {
+	name := filepath.Base(file)
+	if !strings.HasSuffix(name, "_test.go") || filepath.Dir(file) != pkg.Dir {
+		return false
+	}
+	for _, f := range pkg.XTestGoFiles {
+		if f == name {
+			return true
+		}
+	}
+	for _, f := range pkg.TestGoFiles {
+		if f == name {
+			return false
+		}
+	}
+	clause, ok := packageClause(file)
+	return ok && clause == pkg.Name+"_test"
+}
+
+// The package name declared by a Go file.
+func packageClause(file string) (string, bool) {
+	f, err := parser.ParseFile(token.NewFileSet(), file, nil, parser.PackageClauseOnly)
+	if err != nil {
+		return "", false
+	}
+	return f.Name.Name, true
+}